@@ -20,12 +20,11 @@ import (
 	"context"
 	"dexbot/catcherr"
 	"dexbot/config"
+	"dexbot/metrics"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -34,67 +33,80 @@ import (
 
 func TrimURLScheme(path string) string {
 	u, err := url.Parse(path)
-	catcherr.HandleError(err)
+	if err != nil {
+		return path
+	}
 
 	prefix := fmt.Sprint(u.Scheme, `://`)
 	return strings.TrimPrefix(path, prefix)
 }
 
-func GetPrice(ctx context.Context, path string) (price float64, err error) {
-	defer func() { err = catcherr.RecoverAndReturnError() }()
-
-	jar, err := cookiejar.New(nil)
-	catcherr.HandleError(err)
+// GetProduct fetches the page with the adapter registered for its host
+func GetProduct(ctx context.Context, path string) (product Product, err error) {
+	const errorSender = `actions.GetProduct()`
 
-	client := http.Client{
-		Jar:     jar,
-		Timeout: 10 * time.Second,
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return product, catcherr.InvalidInput(errorSender, err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
-	catcherr.HandleError(err)
+	adapter, ok := FindAdapter(u)
+	if !ok {
+		err = catcherr.InvalidInput(errorSender, catcherr.UnsupportedSite(u.Host))
+		// Unsupported hosts come from the users, they are not used as labels
+		metrics.Fetches.WithLabelValues(`unsupported`, metrics.Result(err), ``).Inc()
+		return product, err
+	}
 
-	resp, err := client.Do(req)
-	catcherr.HandleError(err)
-	defer resp.Body.Close()
+	start := time.Now()
+	defer func() {
+		// The source is empty when the price was not found
+		metrics.Fetches.WithLabelValues(u.Host, metrics.Result(err), product.Source).Inc()
+		metrics.FetchDuration.WithLabelValues(u.Host).Observe(time.Since(start).Seconds())
+	}()
 
-	if resp.StatusCode != http.StatusOK {
-		err := catcherr.HTTPStatusCode(http.StatusOK, resp.StatusCode)
-		catcherr.HandleError(err)
+	doc, err := adapter.Fetch(ctx, u)
+	if err != nil {
+		return product, catcherr.FetchFailed(errorSender, err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	catcherr.HandleError(err)
-
-	elems := config.StringSlice(`css_elements`, config.DefaultSeparator)
+	product, err = adapter.Extract(doc)
+	if err != nil {
+		return product, catcherr.ExtractionFailed(errorSender, err)
+	}
 
-	var priceString string
-	for i := range elems {
-		doc.Find(elems[i]).Each(func(i int, s *goquery.Selection) {
-			priceString = s.Text()
-		})
+	product.Currency = strings.ToUpper(product.Currency)
+	if len(product.Currency) == 0 {
+		product.Currency = config.Get().Currency
+	}
+	return product, nil
+}
 
-		if len(priceString) != 0 {
-			break
-		}
+func FetchDocument(ctx context.Context, path string) (*goquery.Document, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
 	}
 
-	const floatNumbers = `([0-9]*[,]|[.])?[0-9]+`
-	r, err := regexp.Compile(floatNumbers)
-	catcherr.HandleError(err)
+	client := http.Client{
+		Jar:     jar,
+		Timeout: 10 * time.Second,
+	}
 
-	priceString = r.FindString(priceString)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	if len(priceString) == 0 {
-		catcherr.HandleError(catcherr.MissingCSSElement())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	/*	In Go float, numbers are separated by a dot, not a comma,
-		so we replace the sign so that later it will be easier
-		to convert string to float */
-	priceString = strings.ReplaceAll(priceString, `,`, `.`)
+	if resp.StatusCode != http.StatusOK {
+		return nil, catcherr.HTTPStatusCode(http.StatusOK, resp.StatusCode)
+	}
 
-	price, err = strconv.ParseFloat(priceString, 64)
-	catcherr.HandleError(err)
-	return price, err
+	return goquery.NewDocumentFromReader(resp.Body)
 }
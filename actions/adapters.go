@@ -0,0 +1,81 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package actions
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Product is the data an adapter extracts from a product page
+type Product struct {
+	Price     float64
+	Currency  string
+	Title     string
+	Available bool
+	// Source tells which part of the page the price was taken from
+	Source string
+}
+
+// SiteAdapter knows how to get product data from the pages of a particular shop
+type SiteAdapter interface {
+	// Match reports whether the URL is a product page the adapter can handle
+	Match(u *url.URL) bool
+	Fetch(ctx context.Context, u *url.URL) (*goquery.Document, error)
+	Extract(doc *goquery.Document) (Product, error)
+}
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = map[string]SiteAdapter{}
+)
+
+// Register makes the adapter responsible for the host, replacing the previous one
+func Register(host string, adapter SiteAdapter) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+
+	host = strings.ToLower(host)
+	adapters[host] = adapter
+	// The config reloads must not replace it
+	delete(configuredHosts, host)
+}
+
+// FindAdapter returns the adapter registered for the URL host if it matches the URL
+func FindAdapter(u *url.URL) (SiteAdapter, bool) {
+	adaptersMu.RLock()
+	adapter, ok := adapters[strings.ToLower(u.Hostname())]
+	adaptersMu.RUnlock()
+
+	if !ok || !adapter.Match(u) {
+		return nil, false
+	}
+	return adapter, true
+}
+
+func IsAllowedURL(path string) bool {
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return false
+	}
+	_, ok := FindAdapter(u)
+	return ok
+}
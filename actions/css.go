@@ -0,0 +1,176 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package actions
+
+import (
+	"context"
+	"dexbot/catcherr"
+	"dexbot/config"
+	"dexbot/pricing"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// CSSAdapter is the default adapter: it accepts links starting with one of
+// the configured prefixes and finds the product data by CSS selectors.
+type CSSAdapter struct {
+	Links               []string
+	PriceElements       []string
+	TitleElements       []string
+	UnavailableElements []string
+}
+
+func (a *CSSAdapter) Match(u *url.URL) bool {
+	path := u.String()
+	for i := range a.Links {
+		if strings.HasPrefix(path, a.Links[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *CSSAdapter) Fetch(ctx context.Context, u *url.URL) (*goquery.Document, error) {
+	return FetchDocument(ctx, u.String())
+}
+
+// Extract prefers structured product data and falls back to the CSS selectors
+func (a *CSSAdapter) Extract(doc *goquery.Document) (product Product, err error) {
+	if product, ok := ExtractStructured(doc); ok {
+		if len(product.Title) == 0 {
+			product.Title = strings.TrimSpace(findText(doc, a.TitleElements))
+		}
+		return product, nil
+	}
+
+	product.Source = SourceCSS
+	product.Title = strings.TrimSpace(findText(doc, a.TitleElements))
+
+	product.Available = true
+	for i := range a.UnavailableElements {
+		if doc.Find(a.UnavailableElements[i]).Length() != 0 {
+			product.Available = false
+			break
+		}
+	}
+
+	priceString := findText(doc, a.PriceElements)
+	if len(priceString) == 0 {
+		err = catcherr.MissingCSSElement()
+	}
+
+	var price pricing.Price
+	if err == nil {
+		price, err = pricing.Parse(priceString)
+	}
+	if err != nil && !product.Available {
+		// Shops often hide the price of the items which are out of stock
+		return product, nil
+	}
+
+	product.Price, product.Currency = price.Amount, price.Currency
+	return product, err
+}
+
+// findText returns the text of the last element matched by the first selector that matches anything
+func findText(doc *goquery.Document, selectors []string) (text string) {
+	for i := range selectors {
+		doc.Find(selectors[i]).Each(func(i int, s *goquery.Selection) {
+			text = s.Text()
+		})
+
+		if len(text) != 0 {
+			break
+		}
+	}
+	return text
+}
+
+// configuredHosts are the hosts with adapters from the config,
+// they are replaced when the config is reloaded
+var configuredHosts = map[string]bool{}
+
+// RegisterConfiguredSites registers the adapters for the sites and allowed_links
+// from the config, replacing the ones registered from the previous config.
+// Adapters registered with Register for other hosts are left untouched.
+func RegisterConfiguredSites(c *config.Config) error {
+	byHost := map[string]*CSSAdapter{}
+
+	// allowed_links are handled with the global selectors.
+	// The config makes sure a host is in one place only.
+	sites := append(append([]config.Site{}, c.Sites...), config.Site{
+		Links:       c.AllowedLinks,
+		CSSElements: c.CSSElements,
+	})
+
+	for _, site := range sites {
+		found, err := cssAdapters(site)
+		if err != nil {
+			return err
+		}
+		for host, adapter := range found {
+			if _, exists := byHost[host]; !exists {
+				byHost[host] = adapter
+			}
+		}
+	}
+
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+
+	for host := range configuredHosts {
+		delete(adapters, host)
+	}
+	configuredHosts = map[string]bool{}
+
+	for host, adapter := range byHost {
+		if _, exists := adapters[host]; exists {
+			// Registered from the code
+			continue
+		}
+		adapters[host] = adapter
+		configuredHosts[host] = true
+	}
+	return nil
+}
+
+// cssAdapters returns an adapter per host found in the site links
+func cssAdapters(site config.Site) (map[string]*CSSAdapter, error) {
+	byHost := map[string][]string{}
+	for _, link := range site.Links {
+		u, err := url.ParseRequestURI(link)
+		if err != nil {
+			return nil, err
+		}
+
+		host := strings.ToLower(u.Hostname())
+		byHost[host] = append(byHost[host], link)
+	}
+
+	result := map[string]*CSSAdapter{}
+	for host, links := range byHost {
+		result[host] = &CSSAdapter{
+			Links:               links,
+			PriceElements:       site.CSSElements,
+			TitleElements:       site.TitleElements,
+			UnavailableElements: site.UnavailableElements,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,222 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package actions
+
+import (
+	"dexbot/pricing"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Sources of the product data, reported in Product.Source and in the fetch metrics
+const (
+	SourceJSONLD    = `json-ld`
+	SourceMicrodata = `microdata`
+	SourceOpenGraph = `opengraph`
+	SourceCSS       = `css`
+)
+
+// ExtractStructured looks for schema.org/Product data published as JSON-LD or
+// microdata and for OpenGraph product meta tags, in this order.
+func ExtractStructured(doc *goquery.Document) (Product, bool) {
+	extractors := []func(*goquery.Document) (Product, bool){
+		extractJSONLD,
+		extractMicrodata,
+		extractOpenGraph,
+	}
+	for _, extract := range extractors {
+		if product, ok := extract(doc); ok {
+			return product, true
+		}
+	}
+	return Product{}, false
+}
+
+func extractJSONLD(doc *goquery.Document) (product Product, ok bool) {
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+
+		node, found := findJSONLDProduct(data)
+		if !found {
+			return true
+		}
+		product, ok = jsonLDProduct(node)
+		return !ok
+	})
+	return product, ok
+}
+
+// findJSONLDProduct walks arrays and @graph containers looking for a Product node
+func findJSONLDProduct(data interface{}) (map[string]interface{}, bool) {
+	switch v := data.(type) {
+	case []interface{}:
+		for i := range v {
+			if node, ok := findJSONLDProduct(v[i]); ok {
+				return node, true
+			}
+		}
+	case map[string]interface{}:
+		if isJSONLDType(v[`@type`], `Product`) {
+			return v, true
+		}
+		if graph, ok := v[`@graph`]; ok {
+			return findJSONLDProduct(graph)
+		}
+	}
+	return nil, false
+}
+
+func isJSONLDType(t interface{}, name string) bool {
+	switch v := t.(type) {
+	case string:
+		return strings.EqualFold(v, name) || strings.HasSuffix(v, `/`+name)
+	case []interface{}:
+		for i := range v {
+			if isJSONLDType(v[i], name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonLDProduct(node map[string]interface{}) (product Product, ok bool) {
+	product.Title, _ = node[`name`].(string)
+	product.Source = SourceJSONLD
+
+	offers := node[`offers`]
+	if list, isList := offers.([]interface{}); isList && len(list) != 0 {
+		offers = list[0]
+	}
+	offer, isMap := offers.(map[string]interface{})
+	if !isMap {
+		return product, false
+	}
+
+	price, found := offer[`price`]
+	if !found {
+		// AggregateOffer has a price range instead of a single price
+		price = offer[`lowPrice`]
+	}
+
+	switch v := price.(type) {
+	case float64:
+		product.Price = v
+	case string:
+		p, ok := parseStructuredPrice(v)
+		if !ok {
+			return product, false
+		}
+		product.Price = p
+	default:
+		return product, false
+	}
+
+	product.Currency, _ = offer[`priceCurrency`].(string)
+
+	availability, _ := offer[`availability`].(string)
+	product.Available = isAvailable(availability)
+	return product, true
+}
+
+func extractMicrodata(doc *goquery.Document) (product Product, ok bool) {
+	scope := doc.Find(`[itemtype*="schema.org/Product"]`).First()
+	if scope.Length() == 0 {
+		return product, false
+	}
+
+	price, ok := parseStructuredPrice(itemprop(scope, `price`))
+	if !ok {
+		return product, false
+	}
+
+	return Product{
+		Price:     price,
+		Currency:  itemprop(scope, `priceCurrency`),
+		Title:     itemprop(scope, `name`),
+		Available: isAvailable(itemprop(scope, `availability`)),
+		Source:    SourceMicrodata,
+	}, true
+}
+
+// itemprop returns the value of the first microdata property,
+// which is kept either in an attribute or in the element text.
+func itemprop(scope *goquery.Selection, name string) string {
+	s := scope.Find(`[itemprop="` + name + `"]`).First()
+	for _, attr := range []string{`content`, `href`} {
+		if v, ok := s.Attr(attr); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return strings.TrimSpace(s.Text())
+}
+
+func extractOpenGraph(doc *goquery.Document) (product Product, ok bool) {
+	meta := func(properties ...string) string {
+		for i := range properties {
+			s := doc.Find(`meta[property="` + properties[i] + `"]`).First()
+			if v, ok := s.Attr(`content`); ok {
+				return strings.TrimSpace(v)
+			}
+		}
+		return ``
+	}
+
+	price, ok := parseStructuredPrice(meta(`product:price:amount`, `og:price:amount`))
+	if !ok {
+		return product, false
+	}
+
+	return Product{
+		Price:     price,
+		Currency:  meta(`product:price:currency`, `og:price:currency`),
+		Title:     meta(`og:title`),
+		Available: isAvailable(meta(`product:availability`, `og:availability`)),
+		Source:    SourceOpenGraph,
+	}, true
+}
+
+// parseStructuredPrice expects a machine-readable number,
+// but tolerates shops which put the displayed price there.
+func parseStructuredPrice(s string) (float64, bool) {
+	price, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err == nil {
+		return price, true
+	}
+
+	p, err := pricing.Parse(s)
+	return p.Amount, err == nil
+}
+
+// isAvailable understands both schema.org availability URLs and OpenGraph values.
+// Missing availability is treated as in stock.
+func isAvailable(availability string) bool {
+	a := strings.ToLower(availability)
+	a = a[strings.LastIndex(a, `/`)+1:]
+
+	switch strings.ReplaceAll(a, ` `, ``) {
+	case `outofstock`, `oos`, `soldout`, `discontinued`:
+		return false
+	}
+	return true
+}
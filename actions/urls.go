@@ -0,0 +1,64 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package actions
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Query parameters which only track where the visitor came from
+var trackingParams = []string{`fbclid`, `gclid`, `yclid`, `_openstat`}
+
+// CanonicalURL normalises the link, so that different links to the same
+// page are equal: the scheme and host are lowercased, the default port,
+// the fragment, tracking parameters and the trailing slash are removed
+// and the query parameters are sorted.
+func CanonicalURL(path string) string {
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); (u.Scheme == `http` && port == `80`) || (u.Scheme == `https` && port == `443`) {
+		u.Host = u.Hostname()
+	}
+
+	u.Fragment = ``
+	u.RawFragment = ``
+
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, `/`)
+		u.RawPath = ``
+	}
+
+	q := u.Query()
+	for key := range q {
+		if strings.HasPrefix(strings.ToLower(key), `utm_`) {
+			q.Del(key)
+		}
+	}
+	for _, key := range trackingParams {
+		q.Del(key)
+	}
+	// Encode sorts the parameters by key
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
@@ -19,28 +19,14 @@ package catcherr
 
 import (
 	"errors"
-	"fmt"
 	"log"
-
-	tb "gopkg.in/telebot.v3"
 )
 
+// HandleError stops the program. It is meant for the startup code,
+// which cannot do anything useful without e.g. the config file.
 func HandleError(err error) {
 	if err != nil {
-		panic(err)
-	}
-}
-
-func HandleErrorChannel(errChan chan<- error, err error) {
-	if err != nil {
-		errChan <- err
-	}
-}
-
-func HandleAndResponse(msg tb.Context, message string, err error) {
-	if err != nil {
-		LogError(`catcherr.HandleAndResponse()`, msg.Send(message))
-		HandleError(err)
+		log.Fatal(err)
 	}
 }
 
@@ -51,16 +37,11 @@ func LogError(sender string, err error) {
 	}
 }
 
-func Recover(sender string) {
-	if r := recover(); r != nil {
-		const tmpl = `[ Sender: %s ]: %v `
-		log.Printf(tmpl, sender, r)
-	}
-}
-
-func RecoverAndReturnError() (err error) {
-	if r := recover(); r != nil {
-		return errors.New(fmt.Sprint(r))
+// Reply returns the user message attached to the error with WithReply
+func Reply(err error) (string, bool) {
+	var e *Error
+	if errors.As(err, &e) && len(e.Reply) != 0 {
+		return e.Reply, true
 	}
-	return nil
+	return ``, false
 }
@@ -25,7 +25,51 @@ func MissingCSSElement() error {
 	return errors.New(`The required CSS element is missing`)
 }
 
+func MissingPrice(text string) error {
+	return fmt.Errorf(`No price found in %q`, text)
+}
+
+func UnsupportedSite(host string) error {
+	return fmt.Errorf(`No site adapter is registered for %q`, host)
+}
+
 func HTTPStatusCode(correct uint, received int) (err error) {
 	const template = `HTTP StatusCode is not %d. StatusCode: %d`
 	return fmt.Errorf(template, correct, received)
 }
+
+func InvalidPrice(price string) error {
+	return fmt.Errorf(`Invalid price: %q`, price)
+}
+
+func ItemNotFound(num int) error {
+	return fmt.Errorf(`There is no item number %d`, num)
+}
+
+func InvalidDuration(duration string) error {
+	return fmt.Errorf(`Invalid duration: %s`, duration)
+}
+
+func ItemIDNotFound(id int64) error {
+	return fmt.Errorf(`There is no item with ID %d`, id)
+}
+
+func InvalidQuietHours(hours string) error {
+	return fmt.Errorf(`Invalid quiet hours: %s`, hours)
+}
+
+func InvalidWeekday(day string) error {
+	return fmt.Errorf(`Invalid weekday: %s`, day)
+}
+
+func InvalidTimeOfDay(value string) error {
+	return fmt.Errorf(`Invalid time of day: %s`, value)
+}
+
+func InvalidDigest(digest string) error {
+	return fmt.Errorf(`Invalid digest setting: %s`, digest)
+}
+
+func UnsupportedLanguage(lang string) error {
+	return fmt.Errorf(`No messages in %q`, lang)
+}
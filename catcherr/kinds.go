@@ -0,0 +1,77 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package catcherr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors. Every *Error matches one of them with errors.Is,
+// so the bot knows what to tell the user without parsing messages.
+var (
+	ErrInvalidInput     = errors.New(`invalid input`)
+	ErrFetchFailed      = errors.New(`fetch failed`)
+	ErrExtractionFailed = errors.New(`extraction failed`)
+	ErrStorageFailed    = errors.New(`storage failed`)
+	ErrAlreadyExists    = errors.New(`already exists`)
+)
+
+// Error is an error of a particular kind which happened in Op
+type Error struct {
+	Kind error
+	Op   string
+	// Reply is the key of the user message which replaces the default one for the kind
+	Reply string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprint(e.Op, `: `, e.Kind)
+	}
+	return fmt.Sprint(e.Op, `: `, e.Kind, `: `, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+func (e *Error) WithReply(reply string) *Error {
+	e.Reply = reply
+	return e
+}
+
+func InvalidInput(op string, err error) *Error {
+	return &Error{Kind: ErrInvalidInput, Op: op, Err: err}
+}
+
+func FetchFailed(op string, err error) *Error {
+	return &Error{Kind: ErrFetchFailed, Op: op, Err: err}
+}
+
+func ExtractionFailed(op string, err error) *Error {
+	return &Error{Kind: ErrExtractionFailed, Op: op, Err: err}
+}
+
+func StorageFailed(op string, err error) *Error {
+	return &Error{Kind: ErrStorageFailed, Op: op, Err: err}
+}
+
+func AlreadyExists(op string, err error) *Error {
+	return &Error{Kind: ErrAlreadyExists, Op: op, Err: err}
+}
@@ -22,103 +22,367 @@ import (
 	"dexbot/catcherr"
 	"dexbot/database"
 	"dexbot/messages"
-	"fmt"
+	"dexbot/pricing"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	tb "gopkg.in/telebot.v3"
 )
 
-func Handle(bot *tb.Bot) {
+// handler keeps the dependencies of the command handlers
+type handler struct {
+	// ctx is cancelled when the bot shuts down
+	ctx   context.Context
+	store database.Store
+
+	mu sync.Mutex
+	// pendingTargets are the items of the users asked for a target price
+	pendingTargets map[int64]int64
+
+	// running counts the handlers in progress. The updates which come
+	// after stopped is set are ignored, the store is about to be closed.
+	runningMu sync.Mutex
+	running   sync.WaitGroup
+	stopped   bool
+}
+
+// Handle registers the commands of the bot. The returned function waits
+// for the handlers in progress after the bot is stopped, the store
+// may be closed once it returns.
+func Handle(ctx context.Context, bot *tb.Bot, store database.Store) (wait func()) {
 	const (
-		startCMD  = `/start`
-		helpCMD   = `/help`
-		addCMD    = `/add`
-		listCMD   = `/list`
-		deleteCMD = `/rm`
+		startCMD    = `/start`
+		helpCMD     = `/help`
+		addCMD      = `/add`
+		listCMD     = `/list`
+		deleteCMD   = `/rm`
+		historyCMD  = `/history`
+		targetCMD   = `/target`
+		pauseCMD    = `/pause`
+		resumeCMD   = `/resume`
+		settingsCMD = `/settings`
+		langCMD     = `/lang`
 	)
 
-	bot.Handle(startCMD, help)
-	bot.Handle(helpCMD, help)
-	bot.Handle(addCMD, add)
-	bot.Handle(listCMD, list)
-	bot.Handle(deleteCMD, delete)
+	h := &handler{ctx: ctx, store: store, pendingTargets: map[int64]int64{}}
+
+	// The errors are replied in the language of the user
+	bot.Use(h.track, h.localize, replyOnError)
+
+	bot.Handle(startCMD, h.help, measure(startCMD))
+	bot.Handle(helpCMD, h.help, measure(helpCMD))
+	bot.Handle(addCMD, h.add, measure(addCMD))
+	bot.Handle(listCMD, h.list, measure(listCMD))
+	bot.Handle(deleteCMD, h.delete, measure(deleteCMD))
+	bot.Handle(historyCMD, h.history, measure(historyCMD))
+	bot.Handle(targetCMD, h.target, measure(targetCMD))
+	bot.Handle(pauseCMD, h.pause, measure(pauseCMD))
+	bot.Handle(resumeCMD, h.resume, measure(resumeCMD))
+	bot.Handle(settingsCMD, h.settings, measure(settingsCMD))
+	bot.Handle(langCMD, h.lang, measure(langCMD))
+
+	bot.Handle(removeBtn, h.removeCallback, measure(`rm_button`))
+	bot.Handle(targetBtn, h.targetCallback, measure(`target_button`))
+	bot.Handle(historyBtn, h.historyCallback, measure(`history_button`))
+	bot.Handle(pauseBtn, h.pauseCallback, measure(`pause_button`))
+	bot.Handle(pageBtn, h.pageCallback, measure(`page_button`))
+	bot.Handle(tb.OnText, h.text)
+
+	return h.wait
 }
 
-func defaultContextTimeout() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 15*time.Second)
+// track counts the handler in progress until it returns
+func (h *handler) track(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(c tb.Context) error {
+		h.runningMu.Lock()
+		if h.stopped {
+			h.runningMu.Unlock()
+			return nil
+		}
+		h.running.Add(1)
+		h.runningMu.Unlock()
+
+		defer h.running.Done()
+		return next(c)
+	}
 }
 
-func help(msg tb.Context) error { return msg.Send(messages.Help) }
+func (h *handler) wait() {
+	h.runningMu.Lock()
+	h.stopped = true
+	h.runningMu.Unlock()
 
-func add(msg tb.Context) error {
-	defer catcherr.Recover(`commands.add`)
+	h.running.Wait()
+}
 
-	ctx, cancel := defaultContextTimeout()
+func (h *handler) defaultContextTimeout() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(h.ctx, 15*time.Second)
+}
+
+func (h *handler) help(msg tb.Context) error { return msg.Send(locale(msg).Help()) }
+
+func (h *handler) add(msg tb.Context) error {
+	const errorSender = `commands.add()`
+
+	ctx, cancel := h.defaultContextTimeout()
 	defer cancel()
 
-	u, err := url.ParseRequestURI(msg.Args()[0])
-	catcherr.HandleAndResponse(msg, messages.NeedCorrectLink, err)
+	args := msg.Args()
+	if len(args) == 0 || len(args) > 2 {
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.NeedCorrectLink)
+	}
+
+	u, err := url.ParseRequestURI(args[0])
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err).WithReply(messages.NeedCorrectLink)
+	}
 	path := u.String()
 
 	if !isAllowedURL(path) {
-		return msg.Send(messages.NeedCorrectLink)
+		err := catcherr.UnsupportedSite(u.Host)
+		return catcherr.InvalidInput(errorSender, err).WithReply(messages.NeedCorrectLink)
 	}
 
-	price, err := actions.GetPrice(ctx, path)
-	catcherr.HandleAndResponse(msg, messages.NeedCorrectLink, err)
+	var targetPrice float64
+	if len(args) > 1 {
+		targetPrice, err = parseTargetPrice(args[1])
+		if err != nil {
+			return catcherr.InvalidInput(errorSender, err).WithReply(messages.NeedCorrectTarget)
+		}
+	}
 
-	err = database.AddItem(ctx, msg.Sender().ID, path, price)
-	catcherr.HandleAndResponse(msg, messages.InternalError, err)
+	// Saves fetching the page, the database rejects duplicates anyway
+	list, err := h.store.GetItemList(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+	for _, v := range list {
+		if actions.CanonicalURL(v.ItemURL) == actions.CanonicalURL(path) {
+			return catcherr.AlreadyExists(errorSender, nil)
+		}
+	}
 
-	return msg.Send(messages.AddedSuccessfully)
-}
+	product, err := actions.GetProduct(ctx, path)
+	if err != nil {
+		return err
+	}
 
-func list(msg tb.Context) error {
-	defer catcherr.Recover(`commands.list`)
+	// The price of an item which is out of stock is not trustworthy,
+	// the item is tracked without it until it is back
+	price, status, reply := product.Price, database.StatusOK, messages.AddedSuccessfully
+	if !product.Available {
+		price, status, reply = 0, database.StatusUnavailable, messages.AddedUnavailable
+	}
+
+	item := &database.Item{
+		UserID:      msg.Sender().ID,
+		ItemURL:     path,
+		Title:       product.Title,
+		Price:       price,
+		TargetPrice: targetPrice,
+		Currency:    product.Currency,
+	}
+	if err := h.store.AddItem(ctx, item); err != nil {
+		return err
+	}
+
+	err = h.store.AddPriceHistory(ctx, item.ID, price, status)
+	catcherr.LogError(errorSender, err)
 
-	ctx, cancel := defaultContextTimeout()
+	return msg.Send(locale(msg).Text(reply))
+}
+
+func (h *handler) list(msg tb.Context) error {
+	ctx, cancel := h.defaultContextTimeout()
 	defer cancel()
 
-	list, err := database.GetItemList(ctx, msg.Sender().ID)
-	catcherr.HandleAndResponse(msg, messages.InternalError, err)
+	list, err := h.store.GetItemList(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+	l := locale(msg)
 	if len(list) == 0 {
-		return msg.Send(messages.EmptyList)
+		return msg.Send(l.Text(messages.EmptyList))
+	}
+
+	user, err := h.store.GetUser(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	message, markup := listPage(l, user, list, 0)
+	return msg.Send(message, markup, tb.NoPreview)
+}
+
+func (h *handler) delete(msg tb.Context) error {
+	const errorSender = `commands.delete()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	item, _, err := h.itemFromArgs(ctx, msg, errorSender)
+	if err != nil {
+		return withReply(err, messages.RemoveError)
 	}
 
-	message := messages.ListHeader
-	for i, v := range list {
-		link := actions.TrimURLScheme(v.ItemURL)
-		message += fmt.Sprint(i+1, ". ", link, "\n")
+	err = h.store.DeleteItem(ctx, msg.Sender().ID, item.ID)
+	if err != nil {
+		return err
 	}
-	return msg.Send(message, tb.NoPreview)
+	return msg.Send(locale(msg).Text(messages.Removed))
 }
 
-func delete(msg tb.Context) error {
-	defer catcherr.Recover(`commands.delete`)
+func (h *handler) history(msg tb.Context) error {
+	const errorSender = `commands.history()`
 
-	ctx, cancel := defaultContextTimeout()
+	ctx, cancel := h.defaultContextTimeout()
 	defer cancel()
 
-	num, err := strconv.Atoi(msg.Args()[0])
-	catcherr.HandleAndResponse(msg, messages.RemoveError, err)
+	item, num, err := h.itemFromArgs(ctx, msg, errorSender)
+	if err != nil {
+		return withReply(err, messages.HistoryError)
+	}
 
-	if num <= 0 {
-		return msg.Send(messages.RemoveError)
+	observations, err := h.store.GetPriceHistory(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	l := locale(msg)
+	if len(observations) == 0 {
+		return msg.Send(l.Text(messages.EmptyHistory))
 	}
 
-	list, err := database.GetItemList(ctx, msg.Sender().ID)
-	catcherr.HandleAndResponse(msg, messages.RemoveError, err)
-	if num > len(list) {
-		return msg.Send(messages.RemoveError)
+	user, err := h.store.GetUser(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
 	}
 
-	// Numbering starts at 0, but the user gets a list in which numbering starts at 1.
-	item := (list)[num-1].ItemURL
+	return msg.Send(historyMessage(l, user, num, item, observations), tb.NoPreview)
+}
 
-	err = database.DeleteItem(ctx, msg.Sender().ID, item)
-	catcherr.HandleAndResponse(msg, messages.RemoveError, err)
+func (h *handler) target(msg tb.Context) error {
+	const errorSender = `commands.target()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
 
-	return msg.Send(messages.Removed)
+	args := msg.Args()
+	if len(args) != 2 {
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.TargetError)
+	}
+
+	targetPrice, err := parseTargetPrice(args[1])
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err).WithReply(messages.TargetError)
+	}
+
+	item, num, err := h.itemFromArgs(ctx, msg, errorSender)
+	if err != nil {
+		return withReply(err, messages.TargetError)
+	}
+
+	err = h.store.UpdateTargetPrice(ctx, msg.Sender().ID, item.ID, targetPrice)
+	if err != nil {
+		return err
+	}
+
+	l := locale(msg)
+	if targetPrice == 0 {
+		return msg.Send(l.Text(messages.TargetRemoved))
+	}
+	formatted := pricing.Format(targetPrice, item.Currency)
+	return msg.Send(l.Text(messages.TargetSetTemplate, num, formatted))
+}
+
+func (h *handler) pause(msg tb.Context) error {
+	const errorSender = `commands.pause()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	args := msg.Args()
+	if len(args) == 0 || len(args) > 2 {
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.PauseError)
+	}
+
+	var until time.Time
+	if len(args) == 2 {
+		d, err := parsePauseDuration(args[1])
+		if err != nil {
+			return catcherr.InvalidInput(errorSender, err).WithReply(messages.PauseError)
+		}
+		until = time.Now().Add(d)
+	}
+
+	item, num, err := h.itemFromArgs(ctx, msg, errorSender)
+	if err != nil {
+		return withReply(err, messages.PauseError)
+	}
+
+	err = h.store.SetPaused(ctx, msg.Sender().ID, item.ID, true, until)
+	if err != nil {
+		return err
+	}
+
+	l := locale(msg)
+	if until.IsZero() {
+		return msg.Send(l.Text(messages.PausedTemplate, num, num))
+	}
+
+	user, err := h.store.GetUser(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+	formatted := until.In(user.Location()).Format(l.Text(messages.TimeLayout))
+	return msg.Send(l.Text(messages.PausedUntilTemplate, num, formatted))
+}
+
+func (h *handler) resume(msg tb.Context) error {
+	const errorSender = `commands.resume()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	item, num, err := h.itemFromArgs(ctx, msg, errorSender)
+	if err != nil {
+		return withReply(err, messages.ResumeError)
+	}
+
+	err = h.store.SetPaused(ctx, msg.Sender().ID, item.ID, false, time.Time{})
+	if err != nil {
+		return err
+	}
+	return msg.Send(locale(msg).Text(messages.ResumedTemplate, num))
+}
+
+// itemFromArgs returns the item shown in /list under the number from the first argument.
+// Numbering starts at 0, but the user gets a list in which numbering starts at 1.
+func (h *handler) itemFromArgs(
+
+	ctx context.Context,
+	msg tb.Context,
+	errorSender string,
+
+) (item database.Item, num int, err error) {
+
+	args := msg.Args()
+	if len(args) == 0 {
+		return item, num, catcherr.InvalidInput(errorSender, nil)
+	}
+
+	num, err = strconv.Atoi(args[0])
+	if err != nil {
+		return item, num, catcherr.InvalidInput(errorSender, err)
+	}
+
+	list, err := h.store.GetItemList(ctx, msg.Sender().ID)
+	if err != nil {
+		return item, num, err
+	}
+	if num <= 0 || num > len(list) {
+		return item, num, catcherr.InvalidInput(errorSender, catcherr.ItemNotFound(num))
+	}
+	return list[num-1], num, nil
 }
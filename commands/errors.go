@@ -0,0 +1,73 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"dexbot/catcherr"
+	"dexbot/messages"
+	"errors"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// replyOnError is a middleware which tells the user what went wrong
+// in a handler and logs the error.
+func replyOnError(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(msg tb.Context) error {
+		err := next(msg)
+		if err == nil {
+			return nil
+		}
+
+		catcherr.LogError(`commands.replyOnError()`, err)
+		if msg.Callback() != nil {
+			// Stops the loading animation on the button
+			catcherr.LogError(`commands.replyOnError()`, msg.Respond())
+		}
+		return msg.Send(locale(msg).Text(errorReply(err)))
+	}
+}
+
+// errorReply returns the key of the message for the error
+func errorReply(err error) string {
+	if reply, ok := catcherr.Reply(err); ok {
+		return reply
+	}
+
+	switch {
+	case errors.Is(err, catcherr.ErrInvalidInput):
+		return messages.InvalidInput
+	case errors.Is(err, catcherr.ErrFetchFailed):
+		return messages.FetchError
+	case errors.Is(err, catcherr.ErrExtractionFailed):
+		return messages.ExtractionError
+	case errors.Is(err, catcherr.ErrAlreadyExists):
+		return messages.AlreadyTracked
+	default:
+		return messages.InternalError
+	}
+}
+
+// withReply sets the key of the user message for invalid input,
+// other kinds of errors keep their default messages.
+func withReply(err error, reply string) error {
+	var e *catcherr.Error
+	if errors.As(err, &e) && e.Kind == catcherr.ErrInvalidInput {
+		return e.WithReply(reply)
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"dexbot/actions"
+	"dexbot/database"
+	"dexbot/messages"
+	"dexbot/pricing"
+)
+
+// How many price changes /history shows
+const historyLength = 10
+
+// historyMessage shows the times in the zone of the user
+func historyMessage(l *messages.Locale, user database.User, num int, item database.Item, observations []database.PriceHistory) string {
+	message := l.Text(messages.HistoryHeader, num, actions.TrimURLScheme(item.ItemURL))
+	layout := l.Text(messages.TimeLayout)
+	loc := user.Location()
+
+	changes := priceChanges(observations)
+	if len(changes) > historyLength {
+		changes = changes[len(changes)-historyLength:]
+	}
+	for _, v := range changes {
+		date := v.CreatedAt.In(loc).Format(layout)
+		price := pricing.Format(v.Price, item.Currency)
+		message += l.Text(messages.HistoryChangeTemplate, date, price)
+	}
+
+	lowest, highest := priceExtremes(observations)
+	message += l.Text(
+		messages.HistoryExtremesTemplate,
+		pricing.Format(lowest.Price, item.Currency),
+		lowest.CreatedAt.In(loc).Format(layout),
+		pricing.Format(highest.Price, item.Currency),
+		highest.CreatedAt.In(loc).Format(layout),
+	)
+	return message
+}
+
+// priceChanges drops observations that repeat the previous price,
+// so only the moments when the price actually changed are left.
+func priceChanges(observations []database.PriceHistory) (changes []database.PriceHistory) {
+	for i, v := range observations {
+		if i > 0 && v.Price == observations[i-1].Price {
+			continue
+		}
+		changes = append(changes, v)
+	}
+	return changes
+}
+
+// priceExtremes returns the first observations of the lowest and highest prices
+func priceExtremes(observations []database.PriceHistory) (lowest, highest database.PriceHistory) {
+	for i, v := range observations {
+		if i == 0 || v.Price < lowest.Price {
+			lowest = v
+		}
+		if i == 0 || v.Price > highest.Price {
+			highest = v
+		}
+	}
+	return lowest, highest
+}
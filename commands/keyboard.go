@@ -0,0 +1,390 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"context"
+	"dexbot/actions"
+	"dexbot/catcherr"
+	"dexbot/database"
+	"dexbot/messages"
+	"dexbot/pricing"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// How many items a page of /list shows
+const listPageSize = 5
+
+// Buttons of /list. The item buttons carry the item ID and the page,
+// so they keep working after the items above are removed.
+var (
+	removeBtn  = &tb.Btn{Unique: `rm`}
+	targetBtn  = &tb.Btn{Unique: `target`}
+	historyBtn = &tb.Btn{Unique: `history`}
+	pauseBtn   = &tb.Btn{Unique: `pause`}
+	pageBtn    = &tb.Btn{Unique: `page`}
+)
+
+// listPage renders a page of the list with the buttons of its items.
+// Items are numbered as in the whole list, the times are in the zone of the user.
+func listPage(l *messages.Locale, user database.User, list []database.Item, page int) (message string, markup *tb.ReplyMarkup) {
+	now := time.Now()
+
+	pages := (len(list) + listPageSize - 1) / listPageSize
+	page = clampPage(page, pages)
+
+	markup = &tb.ReplyMarkup{}
+	var rows []tb.Row
+
+	message = l.Plural(messages.ListHeader, len(list), len(list))
+	first := page * listPageSize
+	for i := first; i < len(list) && i < first+listPageSize; i++ {
+		v, num := list[i], i+1
+
+		item := messages.ListItem{
+			Num:    num,
+			Title:  v.Title,
+			URL:    actions.TrimURLScheme(v.ItemURL),
+			Price:  pricing.Format(v.Price, v.Currency),
+			Paused: v.IsPaused(now),
+		}
+		if v.TargetPrice > 0 {
+			item.Target = pricing.Format(v.TargetPrice, v.Currency)
+		}
+		if item.Paused && !v.PausedUntil.IsZero() {
+			item.PausedUntil = v.PausedUntil.In(user.Location()).Format(l.Text(messages.TimeLayout))
+		}
+		message += l.ListItem(item) + "\n"
+
+		pauseText := messages.PauseButton
+		if item.Paused {
+			pauseText = messages.ResumeButton
+		}
+
+		id, p := strconv.FormatInt(v.ID, 10), strconv.Itoa(page)
+		rows = append(rows, markup.Row(
+			markup.Data(l.Text(messages.RemoveButton, num), removeBtn.Unique, id, p),
+			markup.Data(l.Text(pauseText, num), pauseBtn.Unique, id, p),
+			markup.Data(l.Text(messages.TargetButton, num), targetBtn.Unique, id),
+			markup.Data(l.Text(messages.HistoryButton, num), historyBtn.Unique, id),
+			markup.URL(l.Text(messages.OpenButton, num), v.ItemURL),
+		))
+	}
+
+	if pages > 1 {
+		message += l.Text(messages.ListPageTemplate, page+1, pages)
+
+		var nav []tb.Btn
+		if page > 0 {
+			nav = append(nav, markup.Data(l.Text(messages.PrevButton), pageBtn.Unique, strconv.Itoa(page-1)))
+		}
+		if page < pages-1 {
+			nav = append(nav, markup.Data(l.Text(messages.NextButton), pageBtn.Unique, strconv.Itoa(page+1)))
+		}
+		rows = append(rows, markup.Row(nav...))
+	}
+
+	markup.Inline(rows...)
+	return message, markup
+}
+
+func clampPage(page, pages int) int {
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	return page
+}
+
+// showPage replaces the list in the message with the button by its page
+func (h *handler) showPage(ctx context.Context, c tb.Context, page int) error {
+	list, err := h.store.GetItemList(ctx, c.Sender().ID)
+	if err != nil {
+		return err
+	}
+	l := locale(c)
+	if len(list) == 0 {
+		return c.Edit(l.Text(messages.EmptyList))
+	}
+
+	user, err := h.store.GetUser(ctx, c.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	message, markup := listPage(l, user, list, page)
+	err = c.Edit(message, markup, tb.NoPreview)
+	if errors.Is(err, tb.ErrSameMessageContent) || errors.Is(err, tb.ErrMessageNotModified) {
+		return nil
+	}
+	return err
+}
+
+func (h *handler) pageCallback(c tb.Context) error {
+	const errorSender = `commands.pageCallback()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	page, err := strconv.Atoi(c.Data())
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err)
+	}
+
+	if err := h.showPage(ctx, c, page); err != nil {
+		return err
+	}
+	return c.Respond()
+}
+
+func (h *handler) removeCallback(c tb.Context) error {
+	const errorSender = `commands.removeCallback()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	args := c.Args()
+	if len(args) != 2 {
+		return catcherr.InvalidInput(errorSender, nil)
+	}
+	page, err := strconv.Atoi(args[1])
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err)
+	}
+
+	item, _, err := h.itemFromCallback(ctx, c, errorSender)
+	if errors.Is(err, catcherr.ErrInvalidInput) {
+		// Removed with another button or command
+		return h.respondItemGone(ctx, c, page)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = h.store.DeleteItem(ctx, c.Sender().ID, item.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.showPage(ctx, c, page); err != nil {
+		return err
+	}
+	return c.Respond(&tb.CallbackResponse{Text: locale(c).Text(messages.Removed)})
+}
+
+// pauseCallback pauses the item until it is resumed, or resumes the paused one
+func (h *handler) pauseCallback(c tb.Context) error {
+	const errorSender = `commands.pauseCallback()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	args := c.Args()
+	if len(args) != 2 {
+		return catcherr.InvalidInput(errorSender, nil)
+	}
+	page, err := strconv.Atoi(args[1])
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err)
+	}
+
+	item, num, err := h.itemFromCallback(ctx, c, errorSender)
+	if errors.Is(err, catcherr.ErrInvalidInput) {
+		return h.respondItemGone(ctx, c, page)
+	}
+	if err != nil {
+		return err
+	}
+
+	paused := !item.IsPaused(time.Now())
+	err = h.store.SetPaused(ctx, c.Sender().ID, item.ID, paused, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	if err := h.showPage(ctx, c, page); err != nil {
+		return err
+	}
+
+	l := locale(c)
+	response := l.Text(messages.ResumedAnswerTemplate, num)
+	if paused {
+		response = l.Text(messages.PausedAnswerTemplate, num)
+	}
+	return c.Respond(&tb.CallbackResponse{Text: response})
+}
+
+func (h *handler) historyCallback(c tb.Context) error {
+	const errorSender = `commands.historyCallback()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	l := locale(c)
+	item, num, err := h.itemFromCallback(ctx, c, errorSender)
+	if errors.Is(err, catcherr.ErrInvalidInput) {
+		return c.Respond(&tb.CallbackResponse{Text: l.Text(messages.ItemGone)})
+	}
+	if err != nil {
+		return err
+	}
+
+	observations, err := h.store.GetPriceHistory(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.store.GetUser(ctx, c.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	message := l.Text(messages.EmptyHistory)
+	if len(observations) > 0 {
+		message = historyMessage(l, user, num, item, observations)
+	}
+	if err := c.Send(message, tb.NoPreview); err != nil {
+		return err
+	}
+	return c.Respond()
+}
+
+// targetCallback asks for the target price, the answer is handled by text
+func (h *handler) targetCallback(c tb.Context) error {
+	const errorSender = `commands.targetCallback()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	l := locale(c)
+	item, num, err := h.itemFromCallback(ctx, c, errorSender)
+	if errors.Is(err, catcherr.ErrInvalidInput) {
+		return c.Respond(&tb.CallbackResponse{Text: l.Text(messages.ItemGone)})
+	}
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.pendingTargets[c.Sender().ID] = item.ID
+	h.mu.Unlock()
+
+	err = c.Send(l.Text(messages.TargetPrompt, num), &tb.ReplyMarkup{ForceReply: true})
+	if err != nil {
+		return err
+	}
+	return c.Respond()
+}
+
+// text sets the target price asked for by targetCallback. The question
+// is answered once, other text messages are ignored.
+func (h *handler) text(c tb.Context) error {
+	const errorSender = `commands.text()`
+
+	h.mu.Lock()
+	itemID, ok := h.pendingTargets[c.Sender().ID]
+	delete(h.pendingTargets, c.Sender().ID)
+	h.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	// Unlike the command arguments the price may be written as "1 990"
+	targetPrice, err := parseTargetPrice(strings.Join(strings.Fields(c.Text()), ``))
+	if err != nil {
+		return catcherr.InvalidInput(errorSender, err).WithReply(messages.NeedTargetOnly)
+	}
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	l := locale(c)
+	item, num, err := h.itemByID(ctx, c.Sender().ID, itemID, errorSender)
+	if errors.Is(err, catcherr.ErrInvalidInput) {
+		return c.Send(l.Text(messages.ItemGone))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = h.store.UpdateTargetPrice(ctx, c.Sender().ID, item.ID, targetPrice)
+	if err != nil {
+		return err
+	}
+
+	if targetPrice == 0 {
+		return c.Send(l.Text(messages.TargetRemoved))
+	}
+	formatted := pricing.Format(targetPrice, item.Currency)
+	return c.Send(l.Text(messages.TargetSetTemplate, num, formatted))
+}
+
+func (h *handler) respondItemGone(ctx context.Context, c tb.Context, page int) error {
+	if err := h.showPage(ctx, c, page); err != nil {
+		return err
+	}
+	return c.Respond(&tb.CallbackResponse{Text: locale(c).Text(messages.ItemGone)})
+}
+
+// itemFromCallback returns the item with the ID from the first callback argument
+func (h *handler) itemFromCallback(
+
+	ctx context.Context,
+	c tb.Context,
+	errorSender string,
+
+) (item database.Item, num int, err error) {
+
+	args := c.Args()
+	if len(args) == 0 {
+		return item, num, catcherr.InvalidInput(errorSender, nil)
+	}
+
+	itemID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return item, num, catcherr.InvalidInput(errorSender, err)
+	}
+	return h.itemByID(ctx, c.Sender().ID, itemID, errorSender)
+}
+
+// itemByID returns the item of the user and its number in /list
+func (h *handler) itemByID(
+
+	ctx context.Context,
+	userID, itemID int64,
+	errorSender string,
+
+) (item database.Item, num int, err error) {
+
+	list, err := h.store.GetItemList(ctx, userID)
+	if err != nil {
+		return item, num, err
+	}
+	for i, v := range list {
+		if v.ID == itemID {
+			return v, i + 1, nil
+		}
+	}
+	return item, num, catcherr.InvalidInput(errorSender, catcherr.ItemIDNotFound(itemID))
+}
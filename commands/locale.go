@@ -0,0 +1,122 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"dexbot/catcherr"
+	"dexbot/messages"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// The key of the user locale in tb.Context
+const localeKey = `locale`
+
+// localize is a middleware which finds the language of the user: the one chosen
+// with /lang or the one of the Telegram client. The latter is remembered,
+// the tracker writes the notifications in it.
+func (h *handler) localize(next tb.HandlerFunc) tb.HandlerFunc {
+	return func(c tb.Context) error {
+		const errorSender = `commands.localize()`
+
+		sender := c.Sender()
+		if sender == nil {
+			return next(c)
+		}
+
+		ctx, cancel := h.defaultContextTimeout()
+		defer cancel()
+
+		user, err := h.store.GetUser(ctx, sender.ID)
+		if err != nil {
+			catcherr.LogError(errorSender, err)
+			c.Set(localeKey, messages.Get(sender.LanguageCode))
+			return next(c)
+		}
+
+		if user.LanguageCode != sender.LanguageCode {
+			user.LanguageCode = sender.LanguageCode
+			catcherr.LogError(errorSender, h.store.SaveUser(ctx, &user))
+		}
+
+		c.Set(localeKey, messages.Get(user.Lang()))
+		return next(c)
+	}
+}
+
+// locale returns the locale of the user found by localize
+func locale(c tb.Context) *messages.Locale {
+	if l, ok := c.Get(localeKey).(*messages.Locale); ok {
+		return l
+	}
+	if sender := c.Sender(); sender != nil {
+		return messages.Get(sender.LanguageCode)
+	}
+	return messages.Get(messages.DefaultLanguage)
+}
+
+// lang shows the languages or chooses one of them, "/lang auto" returns
+// to the language of the Telegram client
+func (h *handler) lang(msg tb.Context) error {
+	const errorSender = `commands.lang()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	args := msg.Args()
+	if len(args) == 0 {
+		l := locale(msg)
+
+		var options string
+		for _, lang := range messages.Languages() {
+			name := messages.Get(lang).Text(messages.LanguageName)
+			options += l.Text(messages.LanguageOption, lang, name)
+		}
+		return msg.Send(l.Text(messages.LanguageTemplate, l.Text(messages.LanguageName), options))
+	}
+	if len(args) != 1 {
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.LanguageError)
+	}
+
+	user, err := h.store.GetUser(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	lang := strings.ToLower(args[0])
+	switch {
+	case lang == `auto`:
+		user.Language = ``
+	case messages.Supported(lang):
+		user.Language = lang
+	default:
+		err := catcherr.UnsupportedLanguage(lang)
+		return catcherr.InvalidInput(errorSender, err).WithReply(messages.LanguageError)
+	}
+
+	if err := h.store.SaveUser(ctx, &user); err != nil {
+		return err
+	}
+
+	// The reply is already in the new language
+	l := messages.Get(user.Lang())
+	if len(user.Language) == 0 {
+		return msg.Send(l.Text(messages.LanguageAuto, l.Text(messages.LanguageName)))
+	}
+	return msg.Send(l.Text(messages.LanguageSet, l.Text(messages.LanguageName)))
+}
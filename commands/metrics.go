@@ -0,0 +1,39 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"dexbot/metrics"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// measure is a middleware which counts the calls of the command
+// and measures how long they take
+func measure(command string) tb.MiddlewareFunc {
+	return func(next tb.HandlerFunc) tb.HandlerFunc {
+		return func(msg tb.Context) error {
+			start := time.Now()
+			err := next(msg)
+
+			metrics.Commands.WithLabelValues(command, metrics.Result(err)).Inc()
+			metrics.CommandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
@@ -0,0 +1,157 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package commands
+
+import (
+	"dexbot/catcherr"
+	"dexbot/database"
+	"dexbot/messages"
+	"fmt"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// settings shows the settings of the user or changes one of them:
+//
+//	/settings tz Europe/Moscow
+//	/settings quiet 23:00-08:00
+//	/settings quiet off
+//	/settings digest daily 9:00
+//	/settings digest weekly mon 9:00
+//	/settings digest off
+func (h *handler) settings(msg tb.Context) error {
+	const errorSender = `commands.settings()`
+
+	ctx, cancel := h.defaultContextTimeout()
+	defer cancel()
+
+	user, err := h.store.GetUser(ctx, msg.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	l := locale(msg)
+	args := msg.Args()
+	if len(args) == 0 {
+		return msg.Send(settingsMessage(l, user))
+	}
+	if len(args) < 2 || (args[0] != `digest` && len(args) != 2) {
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.SettingsError)
+	}
+
+	var reply string
+	switch args[0] {
+	case `tz`:
+		loc, err := database.LoadLocation(args[1])
+		if err != nil {
+			return catcherr.InvalidInput(errorSender, err).WithReply(messages.SettingsError)
+		}
+
+		user.Timezone = loc.String()
+		now := time.Now().In(loc).Format(l.Text(messages.TimeLayout))
+		reply = l.Text(messages.TimezoneSetTemplate, user.Timezone, now)
+	case `quiet`:
+		if args[1] == `off` {
+			user.QuietFrom, user.QuietTo = 0, 0
+			reply = l.Text(messages.QuietHoursRemoved)
+			break
+		}
+
+		user.QuietFrom, user.QuietTo, err = parseQuietHours(args[1])
+		if err != nil {
+			return catcherr.InvalidInput(errorSender, err).WithReply(messages.SettingsError)
+		}
+		reply = l.Text(messages.QuietHoursSetTemplate, formatClock(user.QuietFrom), formatClock(user.QuietTo))
+	case `digest`:
+		reply, err = setDigest(l, &user, args[1:])
+		if err != nil {
+			return catcherr.InvalidInput(errorSender, err).WithReply(messages.SettingsError)
+		}
+	default:
+		return catcherr.InvalidInput(errorSender, nil).WithReply(messages.SettingsError)
+	}
+
+	if err := h.store.SaveUser(ctx, &user); err != nil {
+		return err
+	}
+	return msg.Send(reply)
+}
+
+func settingsMessage(l *messages.Locale, user database.User) string {
+	timezone := user.Location().String()
+
+	quietHours := l.Text(messages.QuietHoursOff)
+	if user.HasQuietHours() {
+		from, to := formatClock(user.QuietFrom), formatClock(user.QuietTo)
+		quietHours = l.Text(messages.QuietHoursTemplate, from, to)
+	}
+	return l.Text(messages.SettingsTemplate, timezone, quietHours, deliveryMode(l, user))
+}
+
+// setDigest handles the arguments of /settings digest
+func setDigest(l *messages.Locale, user *database.User, args []string) (reply string, err error) {
+	switch {
+	case len(args) == 1 && args[0] == `off`:
+		user.Digest = ``
+		return l.Text(messages.DigestRemoved), nil
+	case len(args) == 2 && args[0] == database.DigestDaily:
+		user.DigestAt, err = parseClock(args[1])
+		if err != nil {
+			return ``, err
+		}
+		user.Digest = database.DigestDaily
+	case len(args) == 3 && args[0] == database.DigestWeekly:
+		day, err := parseWeekday(args[1])
+		if err != nil {
+			return ``, err
+		}
+		user.DigestAt, err = parseClock(args[2])
+		if err != nil {
+			return ``, err
+		}
+		user.Digest, user.DigestDay = database.DigestWeekly, int(day)
+	default:
+		return ``, catcherr.InvalidDigest(strings.Join(args, ` `))
+	}
+	return l.Text(messages.DigestSetTemplate, deliveryMode(l, *user)), nil
+}
+
+func deliveryMode(l *messages.Locale, user database.User) string {
+	switch user.Digest {
+	case database.DigestDaily:
+		return l.Text(messages.DailyDigestTemplate, formatClock(user.DigestAt))
+	case database.DigestWeekly:
+		day := weekdayName(l, time.Weekday(user.DigestDay))
+		return l.Text(messages.WeeklyDigestTemplate, day, formatClock(user.DigestAt))
+	default:
+		return l.Text(messages.InstantDelivery)
+	}
+}
+
+func weekdayName(l *messages.Locale, day time.Weekday) string {
+	if names := l.Weekdays(); int(day) < len(names) {
+		return names[day]
+	}
+	return day.String()
+}
+
+// formatClock turns minutes since midnight into the time of day
+func formatClock(minutes int) string {
+	return fmt.Sprintf(`%02d:%02d`, minutes/60, minutes%60)
+}
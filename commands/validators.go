@@ -17,16 +17,106 @@
 package commands
 
 import (
-	"dexbot/config"
+	"dexbot/actions"
+	"dexbot/catcherr"
+	"dexbot/messages"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// isAllowedURL reports whether some site adapter is able to handle the link
 func isAllowedURL(path string) bool {
-	allowed := config.StringSlice(`allowed_links`, config.DefaultSeparator)
-	for i := range allowed {
-		if strings.HasPrefix(path, allowed[i]) {
-			return true
+	return actions.IsAllowedURL(path)
+}
+
+// parsePauseDuration accepts Go durations and days, e.g. 12h or 7d
+func parsePauseDuration(s string) (d time.Duration, err error) {
+	if strings.HasSuffix(s, `d`) {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, `d`))
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if d <= 0 {
+		return 0, catcherr.InvalidDuration(s)
+	}
+	return d, nil
+}
+
+// parseTargetPrice accepts both dot and comma as a decimal separator
+func parseTargetPrice(s string) (float64, error) {
+	s = strings.ReplaceAll(s, `,`, `.`)
+
+	price, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if price < 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return 0, catcherr.InvalidPrice(s)
+	}
+	return price, nil
+}
+
+// parseQuietHours accepts the intervals like 23:00-08:00 or 23-8
+// and returns them as minutes since midnight
+func parseQuietHours(s string) (from, to int, err error) {
+	start, end, ok := strings.Cut(s, `-`)
+	if !ok {
+		return 0, 0, catcherr.InvalidQuietHours(s)
+	}
+
+	from, err = parseClock(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err = parseClock(end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if from == to {
+		return 0, 0, catcherr.InvalidQuietHours(s)
+	}
+	return from, to, nil
+}
+
+// parseClock accepts the time of day like 08:30 or 8
+func parseClock(s string) (minutes int, err error) {
+	hours, mins, hasMinutes := strings.Cut(s, `:`)
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, catcherr.InvalidTimeOfDay(s)
+	}
+
+	var m int
+	if hasMinutes {
+		m, err = strconv.Atoi(mins)
+		if err != nil || len(mins) != 2 || m < 0 || m > 59 {
+			return 0, catcherr.InvalidTimeOfDay(s)
+		}
+	}
+	return h*60 + m, nil
+}
+
+// parseWeekday accepts the short names in any of the languages, e.g. mon or пн
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(s)
+	for _, lang := range messages.Languages() {
+		for day, name := range messages.Get(lang).Weekdays() {
+			if s == name {
+				return time.Weekday(day), nil
+			}
 		}
 	}
-	return false
+	return 0, catcherr.InvalidWeekday(s)
 }
@@ -17,31 +17,132 @@
 package config
 
 import (
-	"dexbot/catcherr"
+	"errors"
+	"reflect"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/knadh/koanf"
-	"github.com/knadh/koanf/parsers/yaml"
-	"github.com/knadh/koanf/providers/file"
+	"github.com/mitchellh/mapstructure"
 )
 
-var cfg *koanf.Koanf
+// Config is the configuration of the bot, see config.yml for the meaning of the fields
+type Config struct {
+	BotName  string `koanf:"bot_name"`
+	BotToken string `koanf:"bot_token"`
+	APIURL   string `koanf:"api_url"`
 
-const DefaultSeparator = ` `
+	Poller         string `koanf:"poller"`
+	WebhookListen  string `koanf:"webhook_listen"`
+	WebhookURL     string `koanf:"webhook_url"`
+	WebhookSecret  string `koanf:"webhook_secret"`
+	WebhookTLSCert string `koanf:"webhook_tls_cert"`
+	WebhookTLSKey  string `koanf:"webhook_tls_key"`
 
-func init() {
-	cfg = koanf.New(`.`)
-	file := file.Provider(`config.yml`)
+	HTTPListen string `koanf:"http_listen"`
 
-	err := cfg.Load(file, yaml.Parser())
-	catcherr.HandleError(err)
+	Duration          time.Duration `koanf:"duration"`
+	NotifyDropPercent float64       `koanf:"notify_drop_percent"`
+	TrackerWorkers    int           `koanf:"tracker_workers"`
+	RateLimit         float64       `koanf:"rate_limit"`
+	RateBurst         int           `koanf:"rate_burst"`
+	Currency          string        `koanf:"currency"`
+
+	DBDriver      string `koanf:"db_driver"`
+	DBPath        string `koanf:"db_path"`
+	DBUser        string `koanf:"db_user"`
+	DBPass        string `koanf:"db_pass"`
+	DBHost        string `koanf:"db_host"`
+	DBPort        int    `koanf:"db_port"`
+	DBName        string `koanf:"db_name"`
+	DBSSL         string `koanf:"db_ssl"`
+	DBAutoMigrate bool   `koanf:"db_auto_migrate"`
+
+	CSSElements  []string `koanf:"css_elements"`
+	AllowedLinks []string `koanf:"allowed_links"`
+	Sites        []Site   `koanf:"sites"`
+
+	// Templates are keyed by the language
+	Templates map[string]Templates `koanf:"templates"`
+}
+
+// Site is a shop with its own markup
+type Site struct {
+	Links               []string `koanf:"links"`
+	CSSElements         []string `koanf:"css_elements"`
+	TitleElements       []string `koanf:"title_elements"`
+	UnavailableElements []string `koanf:"unavailable_elements"`
+}
+
+// Default is the config used for the keys missing in the file
+func Default() Config {
+	return Config{
+		BotName:        `DexBot`,
+		Poller:         `long`,
+		Duration:       3 * time.Hour,
+		TrackerWorkers: 8,
+		RateLimit:      1,
+		RateBurst:      2,
+		Currency:       `RUB`,
+		DBDriver:       `postgres`,
+		DBPath:         `dexbot.db`,
+		DBPort:         5432,
+		DBSSL:          `disable`,
+		DBAutoMigrate:  true,
+	}
 }
 
-func String(path string) string {
-	return cfg.String(path)
+var current atomic.Pointer[Config]
+
+// Get returns the loaded config, or the default one before Load
+func Get() *Config {
+	if c := current.Load(); c != nil {
+		return c
+	}
+	c := Default()
+	return &c
+}
+
+// unmarshal decodes the loaded keys over the default config and validates it.
+// The keys which cannot be decoded are reported together with the other problems.
+func unmarshal(k *koanf.Koanf) (c Config, err error) {
+	c = Default()
+	err = k.UnmarshalWithConf(``, &c, koanf.UnmarshalConf{
+		DecoderConfig: &mapstructure.DecoderConfig{
+			DecodeHook: mapstructure.ComposeDecodeHookFunc(
+				mapstructure.StringToTimeDurationHookFunc(),
+				stringToFields,
+			),
+			ErrorUnused:      true,
+			WeaklyTypedInput: true,
+			Result:           &c,
+		},
+	})
+
+	var decodeErr *mapstructure.Error
+	if err != nil && !errors.As(err, &decodeErr) {
+		return c, err
+	}
+
+	err = c.Validate()
+	if decodeErr == nil {
+		return c, err
+	}
+
+	problems := decodeErr.Errors
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		problems = append(problems, verr.Problems...)
+	}
+	return c, &ValidationError{Problems: problems}
 }
 
-func StringSlice(path string, sep string) []string {
-	s := String(path)
-	return strings.Split(s, sep)
+// stringToFields splits strings into lists by spaces. Lists used to be
+// written this way in config.yml, and it is the way to set them with env.
+func stringToFields(from, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || to != reflect.TypeOf([]string{}) {
+		return data, nil
+	}
+	return strings.Fields(data.(string)), nil
 }
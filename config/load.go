@@ -0,0 +1,197 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/basicflag"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/rawbytes"
+)
+
+const (
+	envPrefix  = `DEXBOT_`
+	fileSuffix = `_FILE`
+)
+
+// Load reads and validates the config and returns the arguments left after the flags.
+// The sources are applied in order, the later ones override the earlier:
+//
+//   - the file from --config, DEXBOT_CONFIG or config.yml;
+//   - environment variables, DEXBOT_BOT_TOKEN sets bot_token;
+//   - the contents of the files from DEXBOT_*_FILE variables, for secrets;
+//   - flags named after the keys, --db_driver=sqlite sets db_driver.
+//
+// Env variables and flags set only the top-level keys, the others are ignored.
+func Load(args []string) (rest []string, err error) {
+	path := os.Getenv(envPrefix + `CONFIG`)
+	if len(path) == 0 {
+		path = `config.yml`
+	}
+
+	fs := flag.NewFlagSet(`dexbot`, flag.ContinueOnError)
+	fs.StringVar(&path, `config`, path, `path to the config file`)
+	for key := range overridable {
+		fs.String(key, ``, `sets `+key)
+	}
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: dexbot [flags] [migrate up|down|status]`)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := build(data, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded = source{path: path, flags: fs, data: data}
+	current.Store(&c)
+	return fs.Args(), nil
+}
+
+// source is what the config was loaded from, the reloads use it again
+type source struct {
+	path  string
+	flags *flag.FlagSet
+	// data is the contents of the file
+	data []byte
+	// rejected is the last contents which failed to load
+	rejected []byte
+}
+
+var loaded source
+
+// build layers the other sources over the contents of the config file
+func build(data []byte, fs *flag.FlagSet) (c Config, err error) {
+	k := koanf.New(`.`)
+
+	err = k.Load(rawbytes.Provider(data), yaml.Parser())
+	if err != nil {
+		return c, err
+	}
+
+	err = k.Load(env.Provider(envPrefix, `.`, envKey), nil)
+	if err != nil {
+		return c, err
+	}
+
+	secrets, err := secretFiles()
+	if err != nil {
+		return c, err
+	}
+	err = k.Load(confmap.Provider(secrets, `.`), nil)
+	if err != nil {
+		return c, err
+	}
+
+	err = k.Load(flagProvider(fs), nil)
+	if err != nil {
+		return c, err
+	}
+
+	return unmarshal(k)
+}
+
+// overridable are the top-level keys which env variables and flags may set.
+// The sites and the templates are nested, they are set in the file only.
+var overridable = overridableKeys()
+
+func overridableKeys() (keys map[string]bool) {
+	keys = map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := f.Tag.Get(`koanf`)
+		if len(key) == 0 || f.Type.Kind() == reflect.Map {
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct {
+			continue
+		}
+		keys[key] = true
+	}
+	return keys
+}
+
+// envKey turns DEXBOT_BOT_TOKEN into bot_token. Other DEXBOT_ variables,
+// e.g. DEXBOT_CONFIG or the ones of the tools around the bot, are skipped.
+func envKey(s string) string {
+	key := strings.ToLower(strings.TrimPrefix(s, envPrefix))
+	if !overridable[key] {
+		return ``
+	}
+	return key
+}
+
+// secretFiles reads the files from DEXBOT_*_FILE variables,
+// DEXBOT_DB_PASS_FILE=/run/secrets/db_pass sets db_pass.
+func secretFiles() (secrets map[string]interface{}, err error) {
+	secrets = map[string]interface{}{}
+	for _, v := range os.Environ() {
+		name, path, _ := strings.Cut(v, `=`)
+		if !strings.HasPrefix(name, envPrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+
+		key := strings.TrimSuffix(strings.TrimPrefix(name, envPrefix), fileSuffix)
+		key = strings.ToLower(key)
+		if !overridable[key] {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf(`%s: %w`, name, err)
+		}
+
+		// Files written by editors and echo end with a newline
+		secrets[key] = strings.TrimRight(string(data), "\r\n")
+	}
+	return secrets, nil
+}
+
+// flagProvider reads the flags given on the command line. The others
+// are empty by default and must not override the config.
+func flagProvider(fs *flag.FlagSet) koanf.Provider {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	return basicflag.ProviderWithValue(fs, `.`, func(key, value string) (string, interface{}) {
+		if !given[key] || !overridable[key] {
+			return ``, nil
+		}
+		return key, value
+	})
+}
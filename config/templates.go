@@ -0,0 +1,68 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"io"
+	"text/template"
+)
+
+// Templates replace the messages of the built-in catalog, see config.yml
+type Templates struct {
+	Notification string `koanf:"notification"`
+	ListItem     string `koanf:"list_item"`
+}
+
+// NotificationFields are the fields of the notification template.
+// The values are examples, the templates are checked with them.
+var NotificationFields = map[string]interface{}{
+	`id`:      1,
+	`title`:   `Smartphone`,
+	`url`:     `shop.example/item/1`,
+	`status`:  `The price went down`,
+	`up`:      false,
+	`old`:     `1 500 ₽`,
+	`new`:     `1 200 ₽`,
+	`delta`:   `-300 ₽`,
+	`percent`: `-20.0%`,
+	`lowest`:  `1 100 ₽`,
+	`target`:  `1 250 ₽`,
+}
+
+// ListItemFields are the fields of the template of an item in /list
+var ListItemFields = map[string]interface{}{
+	`id`:           1,
+	`title`:        `Smartphone`,
+	`url`:          `shop.example/item/1`,
+	`price`:        `1 200 ₽`,
+	`target`:       `1 000 ₽`,
+	`paused`:       true,
+	`paused_until`: `01.02.2023 10:00`,
+}
+
+// ParseTemplate parses the template and executes it with the example fields,
+// so the misspelled fields are found before the template is used
+func ParseTemplate(name, text string, fields map[string]interface{}) (*template.Template, error) {
+	t, err := template.New(name).Option(`missingkey=error`).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, fields); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
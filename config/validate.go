@@ -0,0 +1,227 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// MinDuration keeps the shops from being checked too often
+const MinDuration = time.Minute
+
+// ValidationError lists all the problems found in the config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprint("invalid config:\n\t", strings.Join(e.Problems, "\n\t"))
+}
+
+// webhookSecret is the format of the secret token accepted by Telegram
+var webhookSecret = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Validate returns *ValidationError with every problem of the config, or nil
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	if len(c.BotToken) == 0 {
+		addf(`bot_token is required`)
+	}
+	if len(c.APIURL) > 0 && !isAbsoluteURL(c.APIURL) {
+		addf(`api_url: %q is not an absolute URL`, c.APIURL)
+	}
+
+	switch c.Poller {
+	case `long`:
+	case `webhook`:
+		if len(c.WebhookListen) == 0 {
+			addf(`webhook_listen is required for the webhook poller`)
+		}
+		// Telegram sends the updates to https addresses only
+		if len(c.WebhookURL) == 0 {
+			addf(`webhook_url is required for the webhook poller`)
+		} else if !isAbsoluteURL(c.WebhookURL) || !strings.HasPrefix(c.WebhookURL, `https://`) {
+			addf(`webhook_url: %q is not an absolute https URL`, c.WebhookURL)
+		}
+		if len(c.WebhookSecret) > 0 && !webhookSecret.MatchString(c.WebhookSecret) {
+			addf(`webhook_secret may have only 1-256 letters, digits, "_" and "-"`)
+		}
+		if (len(c.WebhookTLSCert) == 0) != (len(c.WebhookTLSKey) == 0) {
+			addf(`webhook_tls_cert and webhook_tls_key are required together`)
+		}
+	default:
+		addf(`poller: unknown %q, expected long or webhook`, c.Poller)
+	}
+
+	if c.Duration < MinDuration {
+		addf(`duration: %s is shorter than %s`, c.Duration, MinDuration)
+	}
+	if c.NotifyDropPercent < 0 || c.NotifyDropPercent >= 100 {
+		addf(`notify_drop_percent: %v is not in [0, 100)`, c.NotifyDropPercent)
+	}
+	if c.TrackerWorkers < 1 {
+		addf(`tracker_workers: %d is less than 1`, c.TrackerWorkers)
+	}
+	if c.RateLimit <= 0 {
+		addf(`rate_limit: %v is not positive`, c.RateLimit)
+	}
+	if c.RateBurst < 1 {
+		addf(`rate_burst: %d is less than 1`, c.RateBurst)
+	}
+	if len(c.Currency) != 3 {
+		addf(`currency: %q is not an ISO 4217 code`, c.Currency)
+	}
+
+	switch c.DBDriver {
+	case `postgres`:
+		for key, value := range map[string]string{
+			`db_user`: c.DBUser,
+			`db_host`: c.DBHost,
+			`db_name`: c.DBName,
+		} {
+			if len(value) == 0 {
+				addf(`%s is required for postgres`, key)
+			}
+		}
+		if c.DBPort < 1 || c.DBPort > 65535 {
+			addf(`db_port: %d is not a port`, c.DBPort)
+		}
+	case `sqlite`:
+		if len(c.DBPath) == 0 {
+			addf(`db_path is required for sqlite`)
+		}
+	case `memory`:
+	default:
+		addf(`db_driver: unknown %q, expected postgres, sqlite or memory`, c.DBDriver)
+	}
+
+	if len(c.AllowedLinks) > 0 && len(c.CSSElements) == 0 {
+		addf(`css_elements are required for allowed_links`)
+	}
+	problems = append(problems, linkProblems(`allowed_links`, c.AllowedLinks)...)
+	problems = append(problems, selectorProblems(`css_elements`, c.CSSElements)...)
+
+	for i, site := range c.Sites {
+		prefix := fmt.Sprintf(`sites[%d].`, i)
+		if len(site.Links) == 0 {
+			addf(`%slinks are required`, prefix)
+		}
+		if len(site.CSSElements) == 0 {
+			addf(`%scss_elements are required`, prefix)
+		}
+		problems = append(problems, linkProblems(prefix+`links`, site.Links)...)
+		problems = append(problems, selectorProblems(prefix+`css_elements`, site.CSSElements)...)
+		problems = append(problems, selectorProblems(prefix+`title_elements`, site.TitleElements)...)
+		problems = append(problems, selectorProblems(prefix+`unavailable_elements`, site.UnavailableElements)...)
+	}
+	problems = append(problems, hostProblems(c)...)
+
+	langs := make([]string, 0, len(c.Templates))
+	for lang := range c.Templates {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		t, prefix := c.Templates[lang], `templates.`+lang+`.`
+		problems = append(problems, templateProblems(prefix+`notification`, t.Notification, NotificationFields)...)
+		problems = append(problems, templateProblems(prefix+`list_item`, t.ListItem, ListItemFields)...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func isAbsoluteURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	return err == nil && len(u.Scheme) > 0 && len(u.Host) > 0
+}
+
+func linkProblems(key string, links []string) (problems []string) {
+	for _, link := range links {
+		if !isAbsoluteURL(link) {
+			problems = append(problems, fmt.Sprintf(`%s: %q is not an absolute URL`, key, link))
+		}
+	}
+	return problems
+}
+
+// hostProblems reports the hosts listed in several sites or in a site and allowed_links.
+// A host is handled with the selectors of one of them, the links of the others
+// would be unsupported.
+func hostProblems(c *Config) (problems []string) {
+	owners := map[string]string{}
+	check := func(key string, links []string) {
+		seen := map[string]bool{}
+		for _, link := range links {
+			u, err := url.ParseRequestURI(link)
+			if err != nil {
+				// Reported by linkProblems
+				continue
+			}
+
+			host := strings.ToLower(u.Hostname())
+			if seen[host] {
+				continue
+			}
+			seen[host] = true
+
+			if owner, ok := owners[host]; ok {
+				problems = append(problems, fmt.Sprintf(`%s: host %q is already in %s, a host may be in one place only`, key, host, owner))
+				continue
+			}
+			owners[host] = key
+		}
+	}
+
+	for i, site := range c.Sites {
+		check(fmt.Sprintf(`sites[%d].links`, i), site.Links)
+	}
+	check(`allowed_links`, c.AllowedLinks)
+	return problems
+}
+
+func selectorProblems(key string, selectors []string) (problems []string) {
+	for _, selector := range selectors {
+		if _, err := cascadia.ParseGroup(selector); err != nil {
+			problems = append(problems, fmt.Sprintf(`%s: %q: %s`, key, selector, err))
+		}
+	}
+	return problems
+}
+
+func templateProblems(key, text string, fields map[string]interface{}) (problems []string) {
+	if len(text) == 0 {
+		return nil
+	}
+	if _, err := ParseTemplate(key, text, fields); err != nil {
+		problems = append(problems, fmt.Sprintf(`%s: %s`, key, err))
+	}
+	return problems
+}
@@ -0,0 +1,168 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"dexbot/catcherr"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets the editors finish writing the file
+const reloadDelay = 500 * time.Millisecond
+
+var (
+	subscribersMu sync.Mutex
+	subscribers   []func(c *Config)
+)
+
+// OnChange registers the function called with the new config after every reload.
+// Packages which read the config with Get on every use do not need it.
+func OnChange(fn func(c *Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Watch reloads the config when its file changes until the context is cancelled.
+// The directory is watched instead of the file, as editors and Kubernetes
+// replace the file rather than write to it.
+func Watch(ctx context.Context) error {
+	const errorSender = `config.Watch()`
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(filepath.Dir(loaded.path)); err != nil {
+		w.Close()
+		return err
+	}
+
+	go func() {
+		defer w.Close()
+
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if isConfigEvent(event) {
+					timer = time.After(reloadDelay)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				catcherr.LogError(errorSender, err)
+			case <-timer:
+				timer = nil
+				reload()
+			}
+		}
+	}()
+	return nil
+}
+
+// isConfigEvent filters out the other files of the directory, e.g. the SQLite
+// database, whose writes would keep putting the reload off. Kubernetes
+// updates the config by replacing the ..data link the file points through.
+func isConfigEvent(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	return name == filepath.Base(loaded.path) || name == `..data`
+}
+
+// reload swaps in the config from the changed file.
+// The invalid config is rejected and the old one is kept.
+func reload() {
+	const errorSender = `config.reload()`
+
+	data, err := os.ReadFile(loaded.path)
+	if err != nil {
+		catcherr.LogError(errorSender, err)
+		return
+	}
+	if bytes.Equal(data, loaded.data) || bytes.Equal(data, loaded.rejected) {
+		return
+	}
+
+	c, err := build(data, loaded.flags)
+	if err != nil {
+		loaded.rejected = data
+		log.Printf("Config reload rejected, the old config is kept: %s", err)
+		return
+	}
+
+	old := Get()
+	loaded.data, loaded.rejected = data, nil
+	current.Store(&c)
+	log.Println(`Config reloaded`)
+
+	if keys := restartRequired(old, &c); len(keys) > 0 {
+		log.Printf(`Changes of %s take effect after a restart`, strings.Join(keys, `, `))
+	}
+
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	for _, fn := range subscribers {
+		fn(&c)
+	}
+}
+
+// restartRequired returns the changed keys which are used only at startup
+func restartRequired(old, c *Config) (keys []string) {
+	changed := map[string]bool{
+		`bot_token`:        old.BotToken != c.BotToken,
+		`api_url`:          old.APIURL != c.APIURL,
+		`poller`:           old.Poller != c.Poller,
+		`webhook_listen`:   old.WebhookListen != c.WebhookListen,
+		`webhook_url`:      old.WebhookURL != c.WebhookURL,
+		`webhook_secret`:   old.WebhookSecret != c.WebhookSecret,
+		`webhook_tls_cert`: old.WebhookTLSCert != c.WebhookTLSCert,
+		`webhook_tls_key`:  old.WebhookTLSKey != c.WebhookTLSKey,
+		`http_listen`:      old.HTTPListen != c.HTTPListen,
+		`db_driver`:        old.DBDriver != c.DBDriver,
+		`db_path`:          old.DBPath != c.DBPath,
+		`db_user`:          old.DBUser != c.DBUser,
+		`db_pass`:          old.DBPass != c.DBPass,
+		`db_host`:          old.DBHost != c.DBHost,
+		`db_port`:          old.DBPort != c.DBPort,
+		`db_name`:          old.DBName != c.DBName,
+		`db_ssl`:           old.DBSSL != c.DBSSL,
+		`db_auto_migrate`:  old.DBAutoMigrate != c.DBAutoMigrate,
+	}
+	for key, ok := range changed {
+		if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -14,93 +14,90 @@
    limitations under the License.
 */
 
+// The package database keeps the tracked items and their price history.
+// The storage is chosen with db_driver: Postgres, SQLite or memory.
 package database
 
 import (
 	"context"
-	"database/sql"
 	"dexbot/catcherr"
 	"dexbot/config"
 	"fmt"
-	"net/url"
-
-	"github.com/uptrace/bun"
-	"github.com/uptrace/bun/dialect/pgdialect"
-	"github.com/uptrace/bun/driver/pgdriver"
-	"github.com/uptrace/bun/extra/bundebug"
+	"time"
 )
 
-var db *bun.DB
-
-func init() {
-	ctx := context.Background()
-
-	var (
-		username = config.String(`db_user`)
-		password = config.String(`db_pass`)
-		sslmode  = config.String(`db_ssl`)
-		path     = config.String(`db_name`)
-
-		user = url.UserPassword(username, password)
-	)
-
-	var host string
-	{
-		h := config.String(`db_host`)
-		p := config.String(`db_port`)
-		host = fmt.Sprint(h, `:`, p)
-	}
-
-	dsn := url.URL{
-		Scheme: "postgres",
-		Host:   host,
-		User:   user,
-		Path:   path,
-	}
-	{
-		q := dsn.Query()
-		q.Add(`sslmode`, sslmode)
-		dsn.RawQuery = q.Encode()
-	}
-
-	pgdb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn.String())))
-
-	// Create a Bun db on top of it.
-	db = bun.NewDB(pgdb, pgdialect.New())
-
-	// Print all queries to stdout.
-	db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+// Store is everything the bot keeps between the restarts
+type Store interface {
+	ItemStore
+	HistoryStore
+	UserStore
+	NotificationStore
+	// Ping checks that the storage is reachable
+	Ping(ctx context.Context) error
+	Close() error
+}
 
-	// Create items table if not exists
-	_, err := db.NewCreateTable().Model((*Item)(nil)).IfNotExists().Exec(ctx)
-	catcherr.HandleError(err)
+type ItemStore interface {
+	// AddItem inserts the item and sets its ID. The same link cannot
+	// be added twice by one user, this is reported as catcherr.ErrAlreadyExists.
+	AddItem(ctx context.Context, item *Item) error
+	// GetItemList returns the items of the user in the order they were added
+	GetItemList(ctx context.Context, userID int64) ([]Item, error)
+	GetAllItems(ctx context.Context) ([]Item, error)
+	// UpdatePrice sets the price found by the tracker. The title
+	// is updated too, unless the page has none.
+	UpdatePrice(ctx context.Context, itemID int64, price float64, currency, title string) error
+	// UpdateTargetPrice sets the price below which the user wants to be notified.
+	// Zero target removes the threshold.
+	UpdateTargetPrice(ctx context.Context, userID, itemID int64, target float64) error
+	// SetPaused pauses the item until the time, or until it is resumed
+	// when the time is zero. The item is resumed when paused is false.
+	SetPaused(ctx context.Context, userID, itemID int64, paused bool, until time.Time) error
+	// DeleteItem deletes the item of the user together with its price history
+	// and the notifications about it which are still held back
+	DeleteItem(ctx context.Context, userID, itemID int64) error
 }
 
-func AddItem(ctx context.Context, userID int64, path string, price float64) error {
-	item := &Item{UserID: userID, ItemURL: path, Price: price}
-	_, err := db.NewInsert().Model(item).Exec(ctx)
-	return err
+type HistoryStore interface {
+	AddPriceHistory(ctx context.Context, itemID int64, price float64, status string) error
+	// GetPriceHistory returns successful observations of the item, oldest first
+	GetPriceHistory(ctx context.Context, itemID int64) ([]PriceHistory, error)
 }
 
-func GetItemList(ctx context.Context, userID int64) (list []Item, err error) {
-	q := db.NewSelect().Model(&list).Where(`id = ?`, userID)
-	err = q.Column(`item_url`, `price`).Order(`i.created_at ASC`).Scan(ctx)
-	return list, err
+type UserStore interface {
+	// GetUser returns the settings of the user. The users who have not
+	// changed anything get the zero settings with the ID set.
+	GetUser(ctx context.Context, userID int64) (User, error)
+	// SaveUser inserts or updates the settings of the user
+	SaveUser(ctx context.Context, user *User) error
 }
 
-func GetAllItems(ctx context.Context) (list []Item, err error) {
-	err = db.NewSelect().Model(&list).Scan(ctx)
-	return list, err
+type NotificationStore interface {
+	// QueueNotification holds the notification back until it is delivered
+	QueueNotification(ctx context.Context, n *Notification) error
+	// GetQueuedNotifications returns the held back notifications of all the users, oldest first
+	GetQueuedNotifications(ctx context.Context) ([]Notification, error)
+	// DeleteNotifications removes the delivered notifications
+	DeleteNotifications(ctx context.Context, ids []int64) error
 }
 
-func UpdatePrice(ctx context.Context, userID int64, item string, price float64) error {
-	i := Item{UserID: userID, ItemURL: item, Price: price}
-	_, err := db.NewUpdate().Model(&i).WherePK().Exec(ctx)
-	return err
+// Open connects to the storage selected in the config
+func Open() (Store, error) {
+	switch driver := config.Get().DBDriver; driver {
+	case ``, `postgres`:
+		return NewPostgres(), nil
+	case `sqlite`:
+		return NewSQLite(config.Get().DBPath)
+	case `memory`:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf(`Unknown db_driver: %q`, driver)
+	}
 }
-func DeleteItem(ctx context.Context, userID int64, item string) (err error) {
-	i := Item{UserID: userID, ItemURL: item}
-	q := db.NewDelete().Model(&i).Where(`id = ?`, userID).Where(`item_url = ?`, item)
-	_, err = q.Exec(ctx)
-	return err
+
+func storageError(sender string, err error) error {
+	if err != nil {
+		return catcherr.StorageFailed(sender, err)
+	}
+	return nil
 }
@@ -0,0 +1,253 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"dexbot/catcherr"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the data in memory until the bot stops.
+// It is meant for tests and for trying the bot out.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	items         map[int64]Item
+	history       map[int64][]PriceHistory
+	users         map[int64]User
+	notifications []Notification
+
+	lastItemID         int64
+	lastHistoryID      int64
+	lastNotificationID int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:   map[int64]Item{},
+		history: map[int64][]PriceHistory{},
+		users:   map[int64]User{},
+	}
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error { return nil }
+
+func (s *MemoryStore) Close() error { return nil }
+
+func (s *MemoryStore) AddItem(ctx context.Context, item *Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.items {
+		if v.UserID == item.UserID && v.ItemURL == item.ItemURL {
+			return catcherr.AlreadyExists(`database.AddItem()`, nil)
+		}
+	}
+
+	s.lastItemID++
+	item.ID = s.lastItemID
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	s.items[item.ID] = *item
+	return nil
+}
+
+func (s *MemoryStore) GetItemList(ctx context.Context, userID int64) (list []Item, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.items {
+		if v.UserID == userID {
+			list = append(list, v)
+		}
+	}
+	sortItems(list)
+	return list, nil
+}
+
+func (s *MemoryStore) GetAllItems(ctx context.Context) (list []Item, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, v := range s.items {
+		list = append(list, v)
+	}
+	sortItems(list)
+	return list, nil
+}
+
+func (s *MemoryStore) UpdatePrice(ctx context.Context, itemID int64, price float64, currency, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.items[itemID]; ok {
+		item.Price, item.Currency = price, currency
+		if len(title) != 0 {
+			item.Title = title
+		}
+		s.items[itemID] = item
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateTargetPrice(ctx context.Context, userID, itemID int64, target float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.items[itemID]; ok && item.UserID == userID {
+		item.TargetPrice = target
+		s.items[itemID] = item
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetPaused(ctx context.Context, userID, itemID int64, paused bool, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !paused {
+		until = time.Time{}
+	}
+
+	if item, ok := s.items[itemID]; ok && item.UserID == userID {
+		item.Paused, item.PausedUntil = paused, until
+		s.items[itemID] = item
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteItem(ctx context.Context, userID, itemID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, ok := s.items[itemID]; ok && item.UserID == userID {
+		delete(s.items, itemID)
+		delete(s.history, itemID)
+
+		kept := s.notifications[:0]
+		for _, v := range s.notifications {
+			if v.ItemID != itemID {
+				kept = append(kept, v)
+			}
+		}
+		s.notifications = kept
+	}
+	return nil
+}
+
+func (s *MemoryStore) AddPriceHistory(ctx context.Context, itemID int64, price float64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHistoryID++
+	s.history[itemID] = append(s.history[itemID], PriceHistory{
+		ID:        s.lastHistoryID,
+		ItemID:    itemID,
+		Price:     price,
+		Status:    status,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (s *MemoryStore) GetPriceHistory(ctx context.Context, itemID int64) (list []PriceHistory, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The history is appended in order, so it is already sorted
+	for _, v := range s.history[itemID] {
+		if v.Status == StatusOK {
+			list = append(list, v)
+		}
+	}
+	return list, nil
+}
+
+func (s *MemoryStore) GetUser(ctx context.Context, userID int64) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if u, ok := s.users[userID]; ok {
+		return u, nil
+	}
+	return User{ID: userID}, nil
+}
+
+func (s *MemoryStore) SaveUser(ctx context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.users[user.ID]; ok {
+		user.CreatedAt = old.CreatedAt
+	} else if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryStore) QueueNotification(ctx context.Context, n *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastNotificationID++
+	n.ID = s.lastNotificationID
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	s.notifications = append(s.notifications, *n)
+	return nil
+}
+
+func (s *MemoryStore) GetQueuedNotifications(ctx context.Context) ([]Notification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// The notifications are appended in order, so they are already sorted
+	return append([]Notification(nil), s.notifications...), nil
+}
+
+func (s *MemoryStore) DeleteNotifications(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := map[int64]bool{}
+	for _, id := range ids {
+		deleted[id] = true
+	}
+
+	kept := s.notifications[:0]
+	for _, v := range s.notifications {
+		if !deleted[v.ID] {
+			kept = append(kept, v)
+		}
+	}
+	s.notifications = kept
+	return nil
+}
+
+func sortItems(list []Item) {
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+}
@@ -0,0 +1,48 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"dexbot/metrics"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// metricsHook counts the queries of SQLStore and measures their duration
+type metricsHook struct{}
+
+func (metricsHook) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (metricsHook) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	// Raw queries keep the indentation of the source
+	operation := strings.TrimSpace(event.Operation())
+
+	result := `ok`
+	if event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows) {
+		result = `error`
+	}
+
+	metrics.DBQueries.WithLabelValues(operation, result).Inc()
+	metrics.DBQueryDuration.WithLabelValues(operation).Observe(time.Since(event.StartTime).Seconds())
+}
@@ -0,0 +1,69 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"dexbot/database/migrations"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+// Migrator is implemented by the stores which keep a schema
+type Migrator interface {
+	Migrate(ctx context.Context) (*migrate.MigrationGroup, error)
+	Rollback(ctx context.Context) (*migrate.MigrationGroup, error)
+	MigrationStatus(ctx context.Context) (migrate.MigrationSlice, error)
+}
+
+func (s *SQLStore) migrator(ctx context.Context) (*migrate.Migrator, error) {
+	m := migrate.NewMigrator(s.db, migrations.Migrations)
+	return m, m.Init(ctx)
+}
+
+// Migrate applies all the migrations which were not applied yet
+func (s *SQLStore) Migrate(ctx context.Context) (*migrate.MigrationGroup, error) {
+	m, err := s.migrator(ctx)
+	if err != nil {
+		return nil, storageError(`database.Migrate()`, err)
+	}
+
+	group, err := m.Migrate(ctx)
+	return group, storageError(`database.Migrate()`, err)
+}
+
+// Rollback reverts the last group of applied migrations
+func (s *SQLStore) Rollback(ctx context.Context) (*migrate.MigrationGroup, error) {
+	m, err := s.migrator(ctx)
+	if err != nil {
+		return nil, storageError(`database.Rollback()`, err)
+	}
+
+	group, err := m.Rollback(ctx)
+	return group, storageError(`database.Rollback()`, err)
+}
+
+// MigrationStatus returns all known migrations, applied ones have a group ID
+func (s *SQLStore) MigrationStatus(ctx context.Context) (migrate.MigrationSlice, error) {
+	m, err := s.migrator(ctx)
+	if err != nil {
+		return nil, storageError(`database.MigrationStatus()`, err)
+	}
+
+	ms, err := m.MigrationsWithStatus(ctx)
+	return ms, storageError(`database.MigrationStatus()`, err)
+}
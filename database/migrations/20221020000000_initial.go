@@ -0,0 +1,83 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+type item struct {
+	bun.BaseModel `bun:"table:items"`
+	UserID        int64  `bun:"id,notnull"`
+	ItemURL       string `bun:",notnull"`
+	Price         float64
+	TargetPrice   float64   `bun:",nullzero"`
+	Currency      string    `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type priceHistory struct {
+	bun.BaseModel `bun:"table:price_history"`
+	ID            int64     `bun:",pk,autoincrement"`
+	UserID        int64     `bun:",notnull"`
+	ItemURL       string    `bun:",notnull"`
+	Price         float64   `bun:",nullzero"`
+	Status        string    `bun:",notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Deployments made before the migrations already have the tables
+		_, err := db.NewCreateTable().Model((*item)(nil)).IfNotExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.NewCreateTable().Model((*priceHistory)(nil)).IfNotExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		// and may lack the columns which appeared later.
+		// Such deployments can only be Postgres, SQLite came with the migrations.
+		if db.Dialect().Name() != dialect.PG {
+			return nil
+		}
+
+		columns := []string{`target_price DOUBLE PRECISION`, `currency VARCHAR`}
+		for _, column := range columns {
+			q := db.NewAddColumn().Model((*item)(nil)).IfNotExists()
+			if _, err := q.ColumnExpr(column).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewDropTable().Model((*priceHistory)(nil)).IfExists().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = db.NewDropTable().Model((*item)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
@@ -0,0 +1,146 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Items get a surrogate primary key instead of the "id" column which kept
+// the Telegram user ID, and the price history refers to the items by it.
+// The tables are rebuilt rather than altered, because adding a primary key
+// to an existing table is not portable between the databases.
+
+type itemV2 struct {
+	bun.BaseModel `bun:"table:items_v2"`
+	ID            int64  `bun:",pk,autoincrement"`
+	UserID        int64  `bun:",notnull,unique:items_user_id_item_url_key"`
+	ItemURL       string `bun:",notnull,unique:items_user_id_item_url_key"`
+	Price         float64
+	TargetPrice   float64   `bun:",nullzero"`
+	Currency      string    `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type priceHistoryV2 struct {
+	bun.BaseModel `bun:"table:price_history_v2"`
+	ID            int64     `bun:",pk,autoincrement"`
+	ItemID        int64     `bun:",notnull"`
+	Price         float64   `bun:",nullzero"`
+	Status        string    `bun:",notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.NewCreateTable().Model((*itemV2)(nil)).Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			// The oldest of the duplicated items wins
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO items_v2 (user_id, item_url, price, target_price, currency, created_at)
+				SELECT id, item_url, price, target_price, currency, created_at
+				FROM items WHERE true ORDER BY created_at
+				ON CONFLICT DO NOTHING`)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.NewCreateTable().Model((*priceHistoryV2)(nil)).
+				ForeignKey(`(item_id) REFERENCES items_v2 (id) ON DELETE CASCADE`).
+				Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO price_history_v2 (item_id, price, status, created_at)
+				SELECT i.id, ph.price, ph.status, ph.created_at
+				FROM price_history AS ph
+				JOIN items_v2 AS i ON i.user_id = ph.user_id AND i.item_url = ph.item_url
+				ORDER BY ph.id`)
+			if err != nil {
+				return err
+			}
+
+			return replaceTables(ctx, tx, map[string]string{
+				`items`:         `items_v2`,
+				`price_history`: `price_history_v2`,
+			})
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.NewCreateTable().Model((*item)(nil)).ModelTableExpr(`items_v1`).Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO items_v1 (id, item_url, price, target_price, currency, created_at)
+				SELECT user_id, item_url, price, target_price, currency, created_at
+				FROM items`)
+			if err != nil {
+				return err
+			}
+
+			q := tx.NewCreateTable().Model((*priceHistory)(nil)).ModelTableExpr(`price_history_v1`)
+			if _, err := q.Exec(ctx); err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO price_history_v1 (user_id, item_url, price, status, created_at)
+				SELECT i.user_id, i.item_url, ph.price, ph.status, ph.created_at
+				FROM price_history AS ph
+				JOIN items AS i ON i.id = ph.item_id
+				ORDER BY ph.id`)
+			if err != nil {
+				return err
+			}
+
+			return replaceTables(ctx, tx, map[string]string{
+				`price_history`: `price_history_v1`,
+				`items`:         `items_v1`,
+			})
+		})
+	})
+}
+
+// replaceTables drops the old tables and gives their names to the new ones
+func replaceTables(ctx context.Context, tx bun.Tx, tables map[string]string) error {
+	// The history refers to the items, so it goes first
+	for _, old := range []string{`price_history`, `items`} {
+		_, err := tx.NewDropTable().Table(old).Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, old := range []string{`items`, `price_history`} {
+		_, err := tx.ExecContext(ctx, `ALTER TABLE ? RENAME TO ?`, bun.Ident(tables[old]), bun.Ident(old))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
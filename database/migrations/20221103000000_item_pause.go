@@ -0,0 +1,56 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Items can be paused for a while or until they are resumed
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		timestamp := `TIMESTAMP`
+		if db.Dialect().Name() == dialect.PG {
+			timestamp = `TIMESTAMPTZ`
+		}
+
+		columns := []string{`paused BOOLEAN NOT NULL DEFAULT false`, `paused_until ` + timestamp}
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, column := range columns {
+				_, err := tx.NewAddColumn().Table(`items`).ColumnExpr(column).Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, column := range []string{`paused_until`, `paused`} {
+				_, err := tx.NewDropColumn().Table(`items`).Column(column).Exec(ctx)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,73 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Users get the settings: the timezone and the quiet hours, and the
+// notifications arising during the quiet hours are held back in a table
+
+type user struct {
+	bun.BaseModel `bun:"table:users"`
+	ID            int64     `bun:",pk"`
+	Timezone      string    `bun:",nullzero"`
+	QuietFrom     int       `bun:",notnull,default:0"`
+	QuietTo       int       `bun:",notnull,default:0"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+type notification struct {
+	bun.BaseModel `bun:"table:notifications"`
+	ID            int64 `bun:",pk,autoincrement"`
+	UserID        int64 `bun:",notnull"`
+	ItemID        int64 `bun:",notnull"`
+	OldPrice      float64
+	NewPrice      float64
+	Currency      string    `bun:",nullzero"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.NewCreateTable().Model((*user)(nil)).Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.NewCreateTable().Model((*notification)(nil)).
+				ForeignKey(`(item_id) REFERENCES items (id) ON DELETE CASCADE`).
+				Exec(ctx)
+			return err
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			_, err := tx.NewDropTable().Model((*notification)(nil)).Exec(ctx)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.NewDropTable().Model((*user)(nil)).Exec(ctx)
+			return err
+		})
+	})
+}
@@ -0,0 +1,57 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"database/sql"
+	"dexbot/config"
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// NewPostgres connects to the Postgres server from the config
+func NewPostgres() *SQLStore {
+	c := config.Get()
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort)),
+		User:   url.UserPassword(c.DBUser, c.DBPass),
+		Path:   c.DBName,
+	}
+	{
+		q := dsn.Query()
+		q.Add(`sslmode`, c.DBSSL)
+		dsn.RawQuery = q.Encode()
+	}
+
+	pgdb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn.String())))
+
+	// Create a Bun db on top of it.
+	return newSQLStore(bun.NewDB(pgdb, pgdialect.New()), isPostgresUniqueViolation)
+}
+
+func isPostgresUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == `23505`
+}
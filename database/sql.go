@@ -0,0 +1,177 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"dexbot/catcherr"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bundebug"
+)
+
+// SQLStore keeps the data in a database supported by bun
+type SQLStore struct {
+	db *bun.DB
+
+	isUniqueViolation func(error) bool
+}
+
+func newSQLStore(db *bun.DB, isUniqueViolation func(error) bool) *SQLStore {
+	// Print all queries to stdout.
+	db.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+	db.AddQueryHook(metricsHook{})
+
+	return &SQLStore{db: db, isUniqueViolation: isUniqueViolation}
+}
+
+func (s *SQLStore) Ping(ctx context.Context) error {
+	return storageError(`database.Ping()`, s.db.PingContext(ctx))
+}
+
+func (s *SQLStore) Close() error { return s.db.Close() }
+
+func (s *SQLStore) AddItem(ctx context.Context, item *Item) error {
+	const errorSender = `database.AddItem()`
+
+	_, err := s.db.NewInsert().Model(item).Exec(ctx)
+	if err != nil && s.isUniqueViolation(err) {
+		return catcherr.AlreadyExists(errorSender, err)
+	}
+	return storageError(errorSender, err)
+}
+
+func (s *SQLStore) GetItemList(ctx context.Context, userID int64) (list []Item, err error) {
+	q := s.db.NewSelect().Model(&list).Where(`user_id = ?`, userID)
+	err = q.Order(`i.created_at ASC`, `i.id ASC`).Scan(ctx)
+	return list, storageError(`database.GetItemList()`, err)
+}
+
+func (s *SQLStore) GetAllItems(ctx context.Context) (list []Item, err error) {
+	err = s.db.NewSelect().Model(&list).Scan(ctx)
+	return list, storageError(`database.GetAllItems()`, err)
+}
+
+func (s *SQLStore) UpdatePrice(ctx context.Context, itemID int64, price float64, currency, title string) error {
+	i := Item{ID: itemID, Price: price, Currency: currency, Title: title}
+	columns := []string{`price`, `currency`}
+	if len(title) != 0 {
+		columns = append(columns, `title`)
+	}
+	_, err := s.db.NewUpdate().Model(&i).Column(columns...).WherePK().Exec(ctx)
+	return storageError(`database.UpdatePrice()`, err)
+}
+
+func (s *SQLStore) UpdateTargetPrice(ctx context.Context, userID, itemID int64, target float64) error {
+	i := Item{ID: itemID, TargetPrice: target}
+	q := s.db.NewUpdate().Model(&i).Column(`target_price`).WherePK()
+	_, err := q.Where(`user_id = ?`, userID).Exec(ctx)
+	return storageError(`database.UpdateTargetPrice()`, err)
+}
+
+func (s *SQLStore) SetPaused(ctx context.Context, userID, itemID int64, paused bool, until time.Time) error {
+	if !paused {
+		until = time.Time{}
+	}
+
+	i := Item{ID: itemID, Paused: paused, PausedUntil: until}
+	q := s.db.NewUpdate().Model(&i).Column(`paused`, `paused_until`).WherePK()
+	_, err := q.Where(`user_id = ?`, userID).Exec(ctx)
+	return storageError(`database.SetPaused()`, err)
+}
+
+func (s *SQLStore) DeleteItem(ctx context.Context, userID, itemID int64) error {
+	const errorSender = `database.DeleteItem()`
+
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		i := Item{ID: itemID}
+		res, err := tx.NewDelete().Model(&i).WherePK().Where(`user_id = ?`, userID).Exec(ctx)
+		if err != nil {
+			return storageError(errorSender, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+
+		q := tx.NewDelete().Model((*PriceHistory)(nil)).Where(`item_id = ?`, itemID)
+		if _, err = q.Exec(ctx); err != nil {
+			return storageError(errorSender, err)
+		}
+
+		q = tx.NewDelete().Model((*Notification)(nil)).Where(`item_id = ?`, itemID)
+		_, err = q.Exec(ctx)
+		return storageError(errorSender, err)
+	})
+}
+
+func (s *SQLStore) AddPriceHistory(ctx context.Context, itemID int64, price float64, status string) error {
+	h := &PriceHistory{ItemID: itemID, Price: price, Status: status}
+	_, err := s.db.NewInsert().Model(h).Exec(ctx)
+	return storageError(`database.AddPriceHistory()`, err)
+}
+
+func (s *SQLStore) GetPriceHistory(ctx context.Context, itemID int64) (list []PriceHistory, err error) {
+	q := s.db.NewSelect().Model(&list).Where(`item_id = ?`, itemID).Where(`status = ?`, StatusOK)
+	err = q.Order(`ph.created_at ASC`, `ph.id ASC`).Scan(ctx)
+	return list, storageError(`database.GetPriceHistory()`, err)
+}
+
+func (s *SQLStore) GetUser(ctx context.Context, userID int64) (User, error) {
+	u := User{ID: userID}
+	err := s.db.NewSelect().Model(&u).WherePK().Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{ID: userID}, nil
+	}
+	return u, storageError(`database.GetUser()`, err)
+}
+
+func (s *SQLStore) SaveUser(ctx context.Context, user *User) error {
+	q := s.db.NewInsert().Model(user).On(`CONFLICT (id) DO UPDATE`)
+	q = q.Set(`timezone = EXCLUDED.timezone`)
+	q = q.Set(`quiet_from = EXCLUDED.quiet_from`)
+	q = q.Set(`quiet_to = EXCLUDED.quiet_to`)
+	q = q.Set(`digest = EXCLUDED.digest`)
+	q = q.Set(`digest_at = EXCLUDED.digest_at`)
+	q = q.Set(`digest_day = EXCLUDED.digest_day`)
+	q = q.Set(`last_digest_at = EXCLUDED.last_digest_at`)
+	q = q.Set(`language = EXCLUDED.language`)
+	_, err := q.Set(`language_code = EXCLUDED.language_code`).Exec(ctx)
+	return storageError(`database.SaveUser()`, err)
+}
+
+func (s *SQLStore) QueueNotification(ctx context.Context, n *Notification) error {
+	_, err := s.db.NewInsert().Model(n).Exec(ctx)
+	return storageError(`database.QueueNotification()`, err)
+}
+
+func (s *SQLStore) GetQueuedNotifications(ctx context.Context) (list []Notification, err error) {
+	err = s.db.NewSelect().Model(&list).Order(`n.created_at ASC`, `n.id ASC`).Scan(ctx)
+	return list, storageError(`database.GetQueuedNotifications()`, err)
+}
+
+func (s *SQLStore) DeleteNotifications(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	q := s.db.NewDelete().Model((*Notification)(nil)).Where(`id IN (?)`, bun.In(ids))
+	_, err := q.Exec(ctx)
+	return storageError(`database.DeleteNotifications()`, err)
+}
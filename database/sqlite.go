@@ -0,0 +1,48 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// NewSQLite opens the database file, creating it if needed
+func NewSQLite(path string) (*SQLStore, error) {
+	dsn := fmt.Sprint(`file:`, path, `?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)`)
+
+	sqldb, err := sql.Open(`sqlite`, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer, concurrent writes would fail with SQLITE_BUSY
+	sqldb.SetMaxOpenConns(1)
+
+	return newSQLStore(bun.NewDB(sqldb, sqlitedialect.New()), isSQLiteUniqueViolation), nil
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
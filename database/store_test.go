@@ -0,0 +1,206 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"context"
+	"dexbot/catcherr"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// The stores must behave the same, the memory one is used
+// to run the bot without a database
+var testStores = map[string]func(t *testing.T) Store{
+	`memory`: func(t *testing.T) Store { return NewMemoryStore() },
+	`sqlite`: func(t *testing.T) Store { return newTestSQLite(t) },
+}
+
+func newTestSQLite(t *testing.T) *SQLStore {
+	t.Helper()
+
+	s, err := NewSQLite(filepath.Join(t.TempDir(), `test.db`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if _, err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStores(t *testing.T) {
+	tests := map[string]func(t *testing.T, s Store){
+		`duplicate item`: testDuplicateItem,
+		`delete item`:    testDeleteItem,
+		`save user`:      testSaveUser,
+	}
+
+	for name, open := range testStores {
+		open := open
+		t.Run(name, func(t *testing.T) {
+			for name, test := range tests {
+				test := test
+				t.Run(name, func(t *testing.T) { test(t, open(t)) })
+			}
+		})
+	}
+}
+
+func testDuplicateItem(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	if err := s.AddItem(ctx, &Item{UserID: 1, ItemURL: `https://shop.example/1`}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := s.AddItem(ctx, &Item{UserID: 1, ItemURL: `https://shop.example/1`})
+	if !errors.Is(err, catcherr.ErrAlreadyExists) {
+		t.Errorf(`AddItem() of the same link = %v, want ErrAlreadyExists`, err)
+	}
+
+	// Other users may track the same link
+	if err := s.AddItem(ctx, &Item{UserID: 2, ItemURL: `https://shop.example/1`}); err != nil {
+		t.Errorf(`AddItem() of another user = %v`, err)
+	}
+}
+
+func testDeleteItem(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	item := &Item{UserID: 1, ItemURL: `https://shop.example/1`, Price: 100}
+	kept := &Item{UserID: 1, ItemURL: `https://shop.example/2`, Price: 200}
+	for _, v := range []*Item{item, kept} {
+		if err := s.AddItem(ctx, v); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddPriceHistory(ctx, v.ID, v.Price, StatusOK); err != nil {
+			t.Fatal(err)
+		}
+		n := &Notification{UserID: 1, ItemID: v.ID, OldPrice: v.Price, NewPrice: v.Price / 2}
+		if err := s.QueueNotification(ctx, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Items of other users are not deleted
+	if err := s.DeleteItem(ctx, 2, item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.GetItemList(ctx, 1); len(list) != 2 {
+		t.Fatalf(`DeleteItem() of another user left %d items, want 2`, len(list))
+	}
+
+	if err := s.DeleteItem(ctx, 1, item.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.GetItemList(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != kept.ID {
+		t.Errorf(`GetItemList() = %v, want only item %d`, list, kept.ID)
+	}
+
+	history, err := s.GetPriceHistory(ctx, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf(`GetPriceHistory() of the deleted item = %v, want none`, history)
+	}
+	if history, _ := s.GetPriceHistory(ctx, kept.ID); len(history) != 1 {
+		t.Errorf(`GetPriceHistory() of the kept item = %v, want one`, history)
+	}
+
+	queued, err := s.GetQueuedNotifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 1 || queued[0].ItemID != kept.ID {
+		t.Errorf(`GetQueuedNotifications() = %v, want only item %d`, queued, kept.ID)
+	}
+}
+
+func testSaveUser(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	u, err := s.GetUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 1 || len(u.Timezone) != 0 {
+		t.Errorf(`GetUser() of a new user = %+v, want the zero settings`, u)
+	}
+
+	u.Timezone, u.QuietFrom, u.QuietTo = `Europe/Moscow`, 23*60, 8*60
+	if err := s.SaveUser(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	u.Timezone, u.Digest, u.LanguageCode = `UTC+03:00`, DigestDaily, `en`
+	if err := s.SaveUser(ctx, &u); err != nil {
+		t.Fatalf(`SaveUser() of an existing user = %v`, err)
+	}
+
+	got, err := s.GetUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Timezone != u.Timezone || got.QuietFrom != u.QuietFrom || got.Digest != u.Digest || got.LanguageCode != u.LanguageCode {
+		t.Errorf(`GetUser() = %+v, want %+v`, got, u)
+	}
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	if err := s.AddItem(ctx, &Item{UserID: 1, ItemURL: `https://shop.example/1`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Rollback(ctx); err != nil {
+		t.Fatalf(`Rollback() = %v`, err)
+	}
+	status, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied := status.Applied(); len(applied) != 0 {
+		t.Errorf(`%d migrations are applied after Rollback(), want none`, len(applied))
+	}
+
+	if _, err := s.Migrate(ctx); err != nil {
+		t.Fatalf(`Migrate() after Rollback() = %v`, err)
+	}
+	status, err = s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unapplied := status.Unapplied(); len(unapplied) != 0 {
+		t.Errorf(`%d migrations are not applied after Migrate(), want none`, len(unapplied))
+	}
+
+	if err := s.AddItem(ctx, &Item{UserID: 1, ItemURL: `https://shop.example/1`}); err != nil {
+		t.Errorf(`AddItem() after the migrations = %v`, err)
+	}
+}
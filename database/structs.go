@@ -24,8 +24,136 @@ import (
 
 type Item struct {
 	bun.BaseModel `bun:"table:items,alias:i"`
-	UserID        int64  `bun:"id,notnull"`
+	ID            int64  `bun:",pk,autoincrement"`
+	UserID        int64  `bun:",notnull"`
 	ItemURL       string `bun:",notnull"`
-	Price         float64
+	// Title is taken from the shop page, it is empty when the page has none
+	Title       string `bun:",nullzero"`
+	Price       float64
+	TargetPrice float64   `bun:",nullzero"`
+	Currency    string    `bun:",nullzero"`
+	Paused      bool      `bun:",notnull"`
+	PausedUntil time.Time `bun:",nullzero"`
+	CreatedAt   time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// IsPaused reports whether the tracker skips the item at the moment.
+// An item paused without PausedUntil stays paused until it is resumed.
+func (i Item) IsPaused(now time.Time) bool {
+	return i.Paused && (i.PausedUntil.IsZero() || now.Before(i.PausedUntil))
+}
+
+const (
+	StatusOK          = `ok`
+	StatusFailed      = `failed`
+	StatusUnavailable = `unavailable`
+)
+
+// PriceHistory is a single observation of an item price made by the tracker
+type PriceHistory struct {
+	bun.BaseModel `bun:"table:price_history,alias:ph"`
+	ID            int64     `bun:",pk,autoincrement"`
+	ItemID        int64     `bun:",notnull"`
+	Price         float64   `bun:",nullzero"`
+	Status        string    `bun:",notnull"`
+	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// User keeps the settings of a Telegram user
+type User struct {
+	bun.BaseModel `bun:"table:users,alias:u"`
+	// ID is the Telegram user ID
+	ID       int64  `bun:",pk"`
+	Timezone string `bun:",nullzero"`
+	// QuietFrom and QuietTo are minutes since midnight in the timezone of the user.
+	// The notifications are held back between them, unless they are equal.
+	QuietFrom int `bun:",notnull"`
+	QuietTo   int `bun:",notnull"`
+	// Digest is DigestDaily or DigestWeekly for the users who get all the
+	// price changes in one message, it is empty for the instant notifications
+	Digest string `bun:",nullzero"`
+	// DigestAt is minutes since midnight, DigestDay is the time.Weekday of the weekly digest
+	DigestAt     int       `bun:",notnull"`
+	DigestDay    int       `bun:",notnull"`
+	LastDigestAt time.Time `bun:",nullzero"`
+	// Language is chosen with /lang, LanguageCode is the last one of the Telegram client
+	Language     string    `bun:",nullzero"`
+	LanguageCode string    `bun:",nullzero"`
+	CreatedAt    time.Time `bun:",nullzero,notnull,default:current_timestamp"`
+}
+
+// Lang returns the language the messages to the user are written in
+func (u User) Lang() string {
+	if len(u.Language) != 0 {
+		return u.Language
+	}
+	return u.LanguageCode
+}
+
+const (
+	DigestDaily  = `daily`
+	DigestWeekly = `weekly`
+)
+
+// Location returns the timezone of the user, UTC when it is not set or is unknown
+func (u User) Location() *time.Location {
+	loc, err := LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// HasQuietHours reports whether the user set the quiet hours
+func (u User) HasQuietHours() bool { return u.QuietFrom != u.QuietTo }
+
+// IsQuiet reports whether the time falls within the quiet hours of the user.
+// The quiet hours may wrap around midnight, e.g. from 23:00 to 08:00.
+func (u User) IsQuiet(now time.Time) bool {
+	if !u.HasQuietHours() {
+		return false
+	}
+
+	local := now.In(u.Location())
+	minute := local.Hour()*60 + local.Minute()
+	if u.QuietFrom < u.QuietTo {
+		return minute >= u.QuietFrom && minute < u.QuietTo
+	}
+	return minute >= u.QuietFrom || minute < u.QuietTo
+}
+
+// DigestDue reports whether the digest was scheduled after the time, e.g. after
+// the oldest change waiting for it, and before now
+func (u User) DigestDue(since, now time.Time) bool {
+	if len(u.Digest) == 0 {
+		return false
+	}
+	return since.Before(u.lastScheduledDigest(now))
+}
+
+// lastScheduledDigest returns the last time at or before now when the digest was scheduled
+func (u User) lastScheduledDigest(now time.Time) time.Time {
+	local := now.In(u.Location())
+
+	t := time.Date(local.Year(), local.Month(), local.Day(), u.DigestAt/60, u.DigestAt%60, 0, 0, local.Location())
+	if t.After(local) {
+		t = t.AddDate(0, 0, -1)
+	}
+	if u.Digest == DigestWeekly {
+		t = t.AddDate(0, 0, -((int(t.Weekday()) - u.DigestDay + 7) % 7))
+	}
+	return t
+}
+
+// Notification is a price change held back to be delivered later,
+// when the quiet hours of the user end or with the digest
+type Notification struct {
+	bun.BaseModel `bun:"table:notifications,alias:n"`
+	ID            int64 `bun:",pk,autoincrement"`
+	UserID        int64 `bun:",notnull"`
+	ItemID        int64 `bun:",notnull"`
+	OldPrice      float64
+	NewPrice      float64
+	Currency      string    `bun:",nullzero"`
 	CreatedAt     time.Time `bun:",nullzero,notnull,default:current_timestamp"`
 }
@@ -0,0 +1,65 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadLocation accepts the IANA timezone names and UTC offsets,
+// e.g. Europe/Moscow, UTC+3 or +05:30. The empty name is UTC.
+// The String of the returned location loads the same location again.
+func LoadLocation(name string) (*time.Location, error) {
+	offset := strings.ToUpper(name)
+	offset = strings.TrimPrefix(strings.TrimPrefix(offset, `UTC`), `GMT`)
+	if len(offset) == 0 || (offset[0] != '+' && offset[0] != '-') {
+		return time.LoadLocation(name)
+	}
+
+	invalid := fmt.Errorf(`Invalid UTC offset: %q`, name)
+
+	hours, minutes, hasMinutes := strings.Cut(offset[1:], `:`)
+	if !isDigits(hours, 1, 2) || (hasMinutes && !isDigits(minutes, 2, 2)) {
+		return nil, invalid
+	}
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	if h > 14 || m >= 60 {
+		return nil, invalid
+	}
+
+	seconds := (h*60 + m) * 60
+	if offset[0] == '-' {
+		seconds = -seconds
+	}
+	return time.FixedZone(fmt.Sprintf(`UTC%c%02d:%02d`, offset[0], h, m), seconds), nil
+}
+
+func isDigits(s string, minLen, maxLen int) bool {
+	if len(s) < minLen || len(s) > maxLen {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"dexbot/tracker"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Pinger is a storage which can tell whether it is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func Database(db Pinger) Check {
+	return func(ctx context.Context) Result {
+		return resultOf(db.Ping(ctx))
+	}
+}
+
+// Telegram checks that the Bot API answers getMe
+func Telegram(bot *tb.Bot) Check {
+	return func(ctx context.Context) Result {
+		// The bot has no way to pass the context to the request
+		done := make(chan error, 1)
+		go func() {
+			_, err := bot.Raw(`getMe`, nil)
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			result := resultOf(err)
+			// Errors of the HTTP client contain the URL with the token
+			result.Error = strings.ReplaceAll(result.Error, bot.Token, `<token>`)
+			return result
+		case <-ctx.Done():
+			return resultOf(ctx.Err())
+		}
+	}
+}
+
+// Tracker fails when no tracker cycle has completed for two budgets.
+// A cycle over the budget is only reported, the checks are just slow then.
+func Tracker() Check {
+	return trackerCheck(true)
+}
+
+// TrackerStatus reports the same as Tracker but never fails. A long first
+// cycle of many rate limited items is not a reason to restart the bot.
+func TrackerStatus() Check {
+	return trackerCheck(false)
+}
+
+func trackerCheck(failStale bool) Check {
+	return func(ctx context.Context) Result {
+		status := tracker.CurrentStatus()
+		if status.Started.IsZero() {
+			return Result{OK: !failStale, Error: `the tracker is not running`}
+		}
+
+		result := Result{OK: true, Details: map[string]interface{}{
+			`budget`: status.Budget.String(),
+		}}
+
+		last := status.Started
+		if cycle := status.LastCycle; cycle != nil {
+			last = cycle.Finished
+			result.Details[`last_cycle`] = cycle.Finished.Format(time.RFC3339)
+			result.Details[`last_cycle_duration`] = cycle.Duration.Round(time.Millisecond).String()
+			result.Details[`budget_exceeded`] = cycle.BudgetExceeded()
+		}
+
+		since := time.Since(last)
+		result.Details[`since_last_cycle`] = since.Round(time.Second).String()
+		if since > 2*status.Budget {
+			result.OK = !failStale
+			result.Error = `no tracker cycle has completed for too long`
+		}
+		return result
+	}
+}
+
+func resultOf(err error) Result {
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+	return Result{OK: true}
+}
@@ -0,0 +1,91 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// The package health serves the liveness and readiness checks
+// for the orchestrator on /healthz and /readyz.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of a check with the details worth showing
+type Result struct {
+	OK      bool                   `json:"ok"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Check reports the state of a dependency of the bot
+type Check func(ctx context.Context) Result
+
+type response struct {
+	OK     bool              `json:"ok"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// checkTimeout bounds all the checks of a request
+const checkTimeout = 5 * time.Second
+
+// Handler runs the checks at the same time and responds with their results.
+// The status is 503 when any of them fails.
+func Handler(checks map[string]Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		resp := response{OK: true, Checks: map[string]Result{}}
+
+		var (
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+		for name, check := range checks {
+			name, check := name, check
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := check(ctx)
+
+				mu.Lock()
+				defer mu.Unlock()
+				resp.Checks[name] = result
+				resp.OK = resp.OK && result.OK
+			}()
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		if !resp.OK {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// Register adds /healthz with the liveness checks
+// and /readyz with the readiness checks to the mux
+func Register(mux *http.ServeMux, liveness, readiness map[string]Check) {
+	mux.Handle(`/healthz`, Handler(liveness))
+	mux.Handle(`/readyz`, Handler(readiness))
+}
@@ -18,27 +18,107 @@
 package main
 
 import (
+	"context"
+	"dexbot/actions"
 	"dexbot/catcherr"
 	"dexbot/commands"
 	"dexbot/config"
+	"dexbot/database"
+	"dexbot/health"
+	"dexbot/metrics"
 	"dexbot/tracker"
-	"time"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	// The timezones of the users are known even on hosts without the tz database
+	_ "time/tzdata"
 
 	tb "gopkg.in/telebot.v3"
 )
 
 func main() {
-	defer catcherr.Recover(`main`)
+	args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	catcherr.HandleError(err)
+	catcherr.HandleError(actions.RegisterConfiguredSites(config.Get()))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := database.Open()
+	catcherr.HandleError(err)
+	defer store.Close()
+
+	if len(args) > 0 && args[0] == `migrate` {
+		code := runMigrate(ctx, store, args[1:])
+		// os.Exit does not run the deferred calls
+		store.Close()
+		stop()
+		os.Exit(code)
+	}
+
+	if m, ok := store.(database.Migrator); ok && config.Get().DBAutoMigrate {
+		_, err := m.Migrate(ctx)
+		catcherr.HandleError(err)
+	}
+
+	config.OnChange(func(c *config.Config) {
+		catcherr.LogError(`main()`, actions.RegisterConfiguredSites(c))
+	})
+	catcherr.LogError(`main()`, config.Watch(ctx))
+
+	poller := newPoller(config.Get())
 
 	settings := tb.Settings{
-		Token:     config.String(`bot_token`),
-		Poller:    &tb.LongPoller{Timeout: 15 * time.Second},
+		URL:       config.Get().APIURL,
+		Token:     config.Get().BotToken,
+		Poller:    poller,
 		ParseMode: tb.ModeMarkdown,
 	}
 	bot, err := tb.NewBot(settings)
 	catcherr.HandleError(err)
+	catcherr.HandleError(registerPoller(bot, poller))
+
+	if addr := config.Get().HTTPListen; len(addr) > 0 {
+		mux := http.NewServeMux()
+		mux.Handle(`/metrics`, metrics.Handler())
+		health.Register(mux,
+			map[string]health.Check{
+				`tracker`: health.TrackerStatus(),
+			},
+			map[string]health.Check{
+				`database`: health.Database(store),
+				`telegram`: health.Telegram(bot),
+				`tracker`:  health.Tracker(),
+			},
+		)
+		go serveHTTP(ctx, addr, mux)
+	}
+
+	trackerDone := make(chan struct{})
+	go func() {
+		tracker.Start(ctx, bot, store)
+		close(trackerDone)
+	}()
 
-	go tracker.Start(bot)
-	commands.Handle(bot)
+	waitHandlers := commands.Handle(ctx, bot, store)
+
+	go func() {
+		<-ctx.Done()
+		log.Println(`Shutting down`)
+		bot.Stop()
+	}()
 	bot.Start()
+
+	// The store is closed only when nothing uses it anymore.
+	// bot.Stop does not wait for the handlers which are still running.
+	waitHandlers()
+	<-trackerDone
 }
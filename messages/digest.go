@@ -0,0 +1,63 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package messages
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Telegram rejects longer messages, the limit is left with some room
+const maxMessageLength = 4000
+
+// DigestChange is a price change shown in the digest
+type DigestChange struct {
+	// Num is the number of the item in /list
+	Num      int
+	URL      string
+	OldPrice string
+	NewPrice string
+	// Percent is negative for the drops
+	Percent float64
+}
+
+// Digest renders the changes in the order they are given.
+// A long digest is split into several messages.
+func (l *Locale) Digest(since string, changes []DigestChange) (parts []string) {
+	message := l.Plural(DigestHeaderTemplate, len(changes), since, len(changes))
+
+	for _, v := range changes {
+		template := DigestUpTemplate
+		if v.Percent < 0 {
+			template = DigestDownTemplate
+		}
+		line := l.Text(template, v.Num, v.URL, v.OldPrice, v.NewPrice, l.formatPercent(v.Percent))
+
+		if utf8.RuneCountInString(message)+utf8.RuneCountInString(line) > maxMessageLength {
+			parts = append(parts, message)
+			message = l.Text(DigestContinued)
+		}
+		message += line
+	}
+	return append(parts, message)
+}
+
+// formatPercent formats the change like -12,5%
+func (l *Locale) formatPercent(percent float64) string {
+	return strings.ReplaceAll(fmt.Sprintf(`%+.1f%%`, percent), `.`, l.Text(DecimalSeparator))
+}
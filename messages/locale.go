@@ -0,0 +1,225 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package messages
+
+import (
+	"dexbot/config"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage is used for the languages without a catalog,
+// and its catalog fills the gaps in the other ones
+const DefaultLanguage = `ru`
+
+//go:embed locales/*.yml
+var catalogFiles embed.FS
+
+// Plural forms of the messages, as named by CLDR
+const (
+	formOne   = `one`
+	formFew   = `few`
+	formMany  = `many`
+	formOther = `other`
+)
+
+// catalog maps the keys to the plural forms of the messages.
+// The messages without plural forms have only the "other" one.
+type catalog map[string]map[string]string
+
+// Locale renders the messages in one language
+type Locale struct {
+	lang    string
+	catalog catalog
+}
+
+var locales = map[string]*Locale{}
+
+func init() {
+	files, err := catalogFiles.ReadDir(`locales`)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range files {
+		lang := strings.TrimSuffix(f.Name(), path.Ext(f.Name()))
+		c, err := readCatalog(path.Join(`locales`, f.Name()))
+		if err != nil {
+			panic(fmt.Sprintf(`messages: %s: %v`, f.Name(), err))
+		}
+		locales[lang] = &Locale{lang: lang, catalog: c}
+	}
+
+	// A key missing from the default catalog is a typo
+	defaults := locales[DefaultLanguage].catalog
+	for lang, l := range locales {
+		for key := range l.catalog {
+			if _, ok := defaults[key]; !ok {
+				panic(fmt.Sprintf(`messages: %s.yml: unknown key %q`, lang, key))
+			}
+		}
+		for key := range templateFields {
+			if _, ok := l.catalog[key]; !ok {
+				continue
+			}
+			if _, err := parseTemplate(key, l.Text(key)); err != nil {
+				panic(fmt.Sprintf(`messages: %s.yml: %v`, lang, err))
+			}
+		}
+	}
+}
+
+func readCatalog(name string) (catalog, error) {
+	data, err := catalogFiles.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	c := catalog{}
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			c[key] = map[string]string{formOther: v}
+		case map[string]interface{}:
+			c[key] = map[string]string{}
+			for form, text := range v {
+				s, ok := text.(string)
+				if !ok || !isPluralForm(form) {
+					return nil, fmt.Errorf(`%s: invalid plural form %q`, key, form)
+				}
+				c[key][form] = s
+			}
+		default:
+			return nil, fmt.Errorf(`%s: expected a string or plural forms`, key)
+		}
+	}
+	return c, nil
+}
+
+func isPluralForm(form string) bool {
+	switch form {
+	case formOne, formFew, formMany, formOther:
+		return true
+	}
+	return false
+}
+
+// Get returns the locale for the Telegram language_code, e.g. en or en-US.
+// The default locale is returned for the languages without a catalog.
+func Get(code string) *Locale {
+	lang, _, _ := strings.Cut(strings.ToLower(code), `-`)
+	if l, ok := locales[lang]; ok {
+		return l
+	}
+	return locales[DefaultLanguage]
+}
+
+// Supported reports whether there is a catalog for the language
+func Supported(lang string) bool {
+	_, ok := locales[lang]
+	return ok
+}
+
+// Languages returns the languages with catalogs, sorted
+func Languages() (langs []string) {
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+func (l *Locale) Lang() string { return l.lang }
+
+// Text returns the message formatted with the arguments
+func (l *Locale) Text(key string, args ...interface{}) string {
+	forms, ok := l.catalog[key]
+	if !ok && l.lang != DefaultLanguage {
+		return Get(DefaultLanguage).Text(key, args...)
+	}
+	return format(forms[formOther], key, args)
+}
+
+// Plural returns the form of the message for the count formatted with the arguments,
+// the count is not added to them
+func (l *Locale) Plural(key string, count int, args ...interface{}) string {
+	forms, ok := l.catalog[key]
+	if !ok && l.lang != DefaultLanguage {
+		return Get(DefaultLanguage).Plural(key, count, args...)
+	}
+
+	text, ok := forms[pluralForm(l.lang, count)]
+	if !ok {
+		text = forms[formOther]
+	}
+	return format(text, key, args)
+}
+
+// Help lists the commands of the bot
+func (l *Locale) Help() string {
+	return l.Text(HelpTemplate, config.Get().BotName)
+}
+
+// Weekdays returns the short names of time.Weekday
+func (l *Locale) Weekdays() []string {
+	return strings.Fields(l.Text(WeekdayNames))
+}
+
+// format falls back to the key, so a missing message is noticed but does not break the reply
+func format(text, key string, args []interface{}) string {
+	if len(text) == 0 {
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// pluralForm returns the CLDR plural form of the integer in the language
+func pluralForm(lang string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+
+	switch lang {
+	case `ru`, `uk`:
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return formOne
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return formFew
+		default:
+			return formMany
+		}
+	default:
+		if n == 1 {
+			return formOne
+		}
+		return formOther
+	}
+}
@@ -14,52 +14,107 @@
    limitations under the License.
 */
 
+// The package messages renders the bot messages in the language of the user.
+// The texts are kept in the catalogs, one file per language in the locales
+// directory, and are looked up by the keys below.
 package messages
 
-import (
-	"dexbot/config"
-	"fmt"
+const (
+	// NotificationTemplate is written as text/template, see Locale.Notification
+	NotificationTemplate = `notification`
+
+	PriceUp   = `price_up`
+	PriceDown = `price_down`
 )
 
 const (
-	ChangedPriceTemplate = `
-	%s
-	📍 ID: *%d*
-	🔗 *%s*
-	
-	▫ Старая: %f
-	🔥 Новая: *%f*`
-
-	PriceUp   = "❌ Цена выросла"
-	PriceDown = "✅ Цена упала"
+	AddedSuccessfully = `added_successfully`
+	AddedUnavailable  = `added_unavailable`
+	NeedCorrectLink   = `need_correct_link`
+	AlreadyTracked    = `already_tracked`
+	NeedCorrectTarget = `need_correct_target`
+
+	// ListHeader has plural forms for the number of the items
+	ListHeader = `list_header`
+	// ListItemTemplate is written as text/template, see Locale.ListItem
+	ListItemTemplate = `list_item`
+	EmptyList        = `empty_list`
+	ListPageTemplate = `list_page`
+
+	RemoveButton  = `remove_button`
+	TargetButton  = `target_button`
+	HistoryButton = `history_button`
+	OpenButton    = `open_button`
+	PauseButton   = `pause_button`
+	ResumeButton  = `resume_button`
+	PrevButton    = `prev_button`
+	NextButton    = `next_button`
+
+	ItemGone       = `item_gone`
+	TargetPrompt   = `target_prompt`
+	NeedTargetOnly = `need_target_only`
+
+	Removed     = `removed`
+	RemoveError = `remove_error`
+
+	HistoryHeader           = `history_header`
+	HistoryChangeTemplate   = `history_change`
+	HistoryExtremesTemplate = `history_extremes`
+	EmptyHistory            = `empty_history`
+	HistoryError            = `history_error`
+
+	TargetSetTemplate = `target_set`
+	TargetRemoved     = `target_removed`
+	TargetError       = `target_error`
+
+	PausedTemplate      = `paused`
+	PausedUntilTemplate = `paused_until`
+	ResumedTemplate     = `resumed`
+	// Answers to the buttons are shown without Markdown
+	PausedAnswerTemplate  = `paused_answer`
+	ResumedAnswerTemplate = `resumed_answer`
+	PauseError            = `pause_error`
+	ResumeError           = `resume_error`
+
+	SettingsTemplate      = `settings`
+	QuietHoursTemplate    = `quiet_hours`
+	QuietHoursOff         = `quiet_hours_off`
+	TimezoneSetTemplate   = `timezone_set`
+	QuietHoursSetTemplate = `quiet_hours_set`
+	QuietHoursRemoved     = `quiet_hours_removed`
+	InstantDelivery       = `instant_delivery`
+	DailyDigestTemplate   = `daily_digest`
+	WeeklyDigestTemplate  = `weekly_digest`
+	DigestSetTemplate     = `digest_set`
+	DigestRemoved         = `digest_removed`
+	SettingsError         = `settings_error`
+
+	// DigestHeaderTemplate has plural forms for the number of the changes
+	DigestHeaderTemplate = `digest_header`
+	DigestContinued      = `digest_continued`
+	DigestDownTemplate   = `digest_down`
+	DigestUpTemplate     = `digest_up`
+
+	LanguageName     = `language_name`
+	LanguageTemplate = `language`
+	LanguageOption   = `language_option`
+	LanguageSet      = `language_set`
+	LanguageAuto     = `language_auto`
+	LanguageError    = `language_error`
+
+	InvalidInput    = `invalid_input`
+	FetchError      = `fetch_error`
+	ExtractionError = `extraction_error`
+
+	InternalError = `internal_error`
+
+	HelpTemplate = `help`
 )
 
+// Formatting conventions of the language
 const (
-	AddedSuccessfully = `✅ Товар успешно добавлен в трекер.`
-	NeedCorrectLink   = "❌ Пожалуйста, отправьте правильную ссылку на товар.\n🔗 Используйте */add <url>*"
-
-	ListHeader = "📝 Список отслеживаемых товаров:\n"
-	EmptyList  = "📝 Список пуст.\n🔗 Используйте */add <url>* чтобы добавить товары."
-
-	Removed     = "✅ Товар успешно удалён из трекера."
-	RemoveError = `❌ Пожалуйста, отправьте правильный ID товара.
-🔗 Используйте */rm <id>*
-
-📝 Если Вы не знаете нужный ID - введите */list*.`
-
-	InternalError = "❌ Произошла внутренняя ошибка.\n⏳ Ожидайте, скоро всё заработает."
+	TimeLayout = `time_layout`
+	// WeekdayNames are the short names of the days from Sunday, separated by spaces
+	WeekdayNames     = `weekday_names`
+	DecimalSeparator = `decimal_separator`
 )
-
-func Help() string {
-	name := config.String(`bot_name`)
-
-	helpMSG := `👤 *%s* 👤
-
-/help - Показать это сообщение.
-/add - Добавить в трекер.
-/list - Список товаров.
-/rm - Удалить из трекера.
-
-🔰 Выгодных покупок! 🔰`
-	return fmt.Sprintf(helpMSG, name)
-}
@@ -0,0 +1,150 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package messages
+
+import (
+	"dexbot/catcherr"
+	"dexbot/config"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Notification is a price change the user is notified about
+type Notification struct {
+	// Num is the number of the item in /list
+	Num    int
+	Title  string
+	URL    string
+	Old    string
+	New    string
+	Delta  string
+	Lowest string
+	Target string
+	// Up is true when the price went up
+	Up bool
+	// Percent is negative for the drops
+	Percent float64
+}
+
+// ListItem is an item shown in /list
+type ListItem struct {
+	Num         int
+	Title       string
+	URL         string
+	Price       string
+	Target      string
+	Paused      bool
+	PausedUntil string
+}
+
+// templateFields are the example fields of the messages written as text/template
+var templateFields = map[string]map[string]interface{}{
+	NotificationTemplate: config.NotificationFields,
+	ListItemTemplate:     config.ListItemFields,
+}
+
+// templates caches the parsed templates by their text,
+// as the text may be changed with the config
+var templates sync.Map
+
+// Notification renders the notification with the template from the config,
+// or with the one from the catalog
+func (l *Locale) Notification(n Notification) string {
+	status := l.Text(PriceDown)
+	if n.Up {
+		status = l.Text(PriceUp)
+	}
+
+	return l.render(NotificationTemplate, map[string]interface{}{
+		`id`:      n.Num,
+		`title`:   escapeMarkdown(n.Title),
+		`url`:     n.URL,
+		`status`:  status,
+		`up`:      n.Up,
+		`old`:     n.Old,
+		`new`:     n.New,
+		`delta`:   n.Delta,
+		`percent`: l.formatPercent(n.Percent),
+		`lowest`:  n.Lowest,
+		`target`:  n.Target,
+	})
+}
+
+// ListItem renders the line of the item in /list
+func (l *Locale) ListItem(item ListItem) string {
+	return l.render(ListItemTemplate, map[string]interface{}{
+		`id`:           item.Num,
+		`title`:        escapeMarkdown(item.Title),
+		`url`:          item.URL,
+		`price`:        item.Price,
+		`target`:       item.Target,
+		`paused`:       item.Paused,
+		`paused_until`: item.PausedUntil,
+	})
+}
+
+func (l *Locale) render(key string, fields map[string]interface{}) string {
+	const errorSender = `messages.render()`
+
+	t, err := parseTemplate(key, l.templateText(key))
+	if err != nil {
+		catcherr.LogError(errorSender, err)
+		return key
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, fields); err != nil {
+		catcherr.LogError(errorSender, err)
+		return key
+	}
+	return b.String()
+}
+
+// templateText returns the template of the language from the config,
+// or the message from the catalog when there is none
+func (l *Locale) templateText(key string) string {
+	t := config.Get().Templates[l.lang]
+	switch {
+	case key == NotificationTemplate && len(t.Notification) != 0:
+		return t.Notification
+	case key == ListItemTemplate && len(t.ListItem) != 0:
+		return t.ListItem
+	}
+	return l.Text(key)
+}
+
+func parseTemplate(key, text string) (*template.Template, error) {
+	if t, ok := templates.Load(text); ok {
+		return t.(*template.Template), nil
+	}
+
+	t, err := config.ParseTemplate(key, text, templateFields[key])
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(text, t)
+	return t, nil
+}
+
+// markdownEscaper escapes the text taken from the shop pages,
+// as it may break the Markdown of the message
+var markdownEscaper = strings.NewReplacer(`_`, `\_`, `*`, `\*`, "`", "\\`", `[`, `\[`)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
@@ -0,0 +1,114 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// The package metrics collects the Prometheus metrics of the bot.
+// They are served on http_listen when it is set in the config.
+package metrics
+
+import (
+	"dexbot/catcherr"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = `dexbot`
+
+var (
+	Fetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      `fetches_total`,
+		Help:      `Product pages fetched, by shop host, result and the source of the price.`,
+	}, []string{`host`, `result`, `source`})
+
+	FetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      `fetch_duration_seconds`,
+		Help:      `Time spent fetching and parsing a product page, by shop host.`,
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 8),
+	}, []string{`host`})
+
+	TrackerCycleDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      `tracker_cycle_duration_seconds`,
+		Help:      `Time spent checking all the tracked items.`,
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+	})
+
+	TrackedItems = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      `tracked_items`,
+		Help:      `Items tracked by the users, the paused ones included.`,
+	})
+
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      `notifications_total`,
+		Help:      `Price change notifications, by result.`,
+	}, []string{`result`})
+
+	Commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      `commands_total`,
+		Help:      `Handled bot commands, by command and result.`,
+	}, []string{`command`, `result`})
+
+	CommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      `command_duration_seconds`,
+		Help:      `Time spent handling a bot command.`,
+		Buckets:   prometheus.DefBuckets,
+	}, []string{`command`})
+
+	DBQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      `db_queries_total`,
+		Help:      `Database queries, by operation and result.`,
+	}, []string{`operation`, `result`})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      `db_query_duration_seconds`,
+		Help:      `Time spent on a database query, by operation.`,
+		Buckets:   prometheus.DefBuckets,
+	}, []string{`operation`})
+)
+
+// Handler serves the metrics in the Prometheus format
+func Handler() http.Handler { return promhttp.Handler() }
+
+// Result is the label value describing the outcome of an operation
+func Result(err error) string {
+	switch {
+	case err == nil:
+		return `ok`
+	case errors.Is(err, catcherr.ErrInvalidInput):
+		return `invalid_input`
+	case errors.Is(err, catcherr.ErrFetchFailed):
+		return `fetch_failed`
+	case errors.Is(err, catcherr.ErrExtractionFailed):
+		return `extraction_failed`
+	case errors.Is(err, catcherr.ErrStorageFailed):
+		return `storage_failed`
+	case errors.Is(err, catcherr.ErrAlreadyExists):
+		return `already_exists`
+	default:
+		return `error`
+	}
+}
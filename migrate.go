@@ -0,0 +1,90 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"dexbot/database"
+	"fmt"
+	"log"
+	"os"
+)
+
+const migrateUsage = `Usage: dexbot migrate up|down|status`
+
+// runMigrate handles the "migrate" subcommand and returns the exit code.
+// It does not exit itself, so main closes the store first.
+func runMigrate(ctx context.Context, store database.Store, args []string) (code int) {
+	m, ok := store.(database.Migrator)
+	if !ok {
+		fmt.Fprintln(os.Stderr, `The configured db_driver has no migrations`)
+		return 2
+	}
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case `up`:
+		group, err := m.Migrate(ctx)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		if group.IsZero() {
+			fmt.Println(`There are no new migrations to run`)
+			return 0
+		}
+		fmt.Println(`Migrated to`, group)
+
+	case `down`:
+		group, err := m.Rollback(ctx)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		if group.IsZero() {
+			fmt.Println(`There are no groups to roll back`)
+			return 0
+		}
+		fmt.Println(`Rolled back`, group)
+
+	case `status`:
+		ms, err := m.MigrationStatus(ctx)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+
+		for _, migration := range ms {
+			status := `pending`
+			if migration.IsApplied() {
+				status = fmt.Sprint(`applied in group `, migration.GroupID)
+			}
+			fmt.Printf("%s\t%s\n", migration, status)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"dexbot/catcherr"
+	"dexbot/config"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// newPoller returns the way the bot receives updates: long polling
+// or a webhook, as selected with the poller option of the config
+func newPoller(c *config.Config) tb.Poller {
+	if c.Poller != `webhook` {
+		return &tb.LongPoller{Timeout: 15 * time.Second}
+	}
+
+	// Telegram sends the updates to the public URL, which is usually
+	// a reverse proxy in front of the listen address
+	webhook := &tb.Webhook{
+		Listen:      c.WebhookListen,
+		SecretToken: c.WebhookSecret,
+		Endpoint:    &tb.WebhookEndpoint{PublicURL: c.WebhookURL},
+	}
+	// The config makes sure both of them are set or none
+	if len(c.WebhookTLSCert) > 0 {
+		webhook.TLS = &tb.WebhookTLS{Cert: c.WebhookTLSCert, Key: c.WebhookTLSKey}
+	}
+	return webhookPoller{webhook}
+}
+
+// registerPoller tells Telegram where to deliver the updates before the bot starts.
+// The pollers themselves only report such errors in the verbose mode.
+func registerPoller(bot *tb.Bot, poller tb.Poller) error {
+	if webhook, ok := poller.(webhookPoller); ok {
+		return bot.SetWebhook(webhook.Webhook)
+	}
+	// getUpdates is refused while a webhook is set
+	return bot.RemoveWebhook()
+}
+
+// webhookPoller receives the updates on the listen address of the webhook.
+// It does not use tb.Webhook.Poll, which sets the webhook again after
+// registerPoller and closes the stop channel already closed by Bot.Stop.
+type webhookPoller struct {
+	*tb.Webhook
+}
+
+func (p webhookPoller) Poll(bot *tb.Bot, dest chan tb.Update, stop chan struct{}) {
+	const errorSender = `main.webhookPoller.Poll()`
+
+	s := &http.Server{Addr: p.Listen, Handler: p.handler(dest, stop)}
+	go func() {
+		<-stop
+		s.Shutdown(context.Background())
+	}()
+
+	var err error
+	if p.TLS != nil {
+		err = s.ListenAndServeTLS(p.TLS.Cert, p.TLS.Key)
+	} else {
+		err = s.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		catcherr.LogError(errorSender, err)
+	}
+}
+
+// handler passes the updates signed with the secret token to the bot
+func (p webhookPoller) handler(dest chan<- tb.Update, stop <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(p.SecretToken) > 0 && r.Header.Get(`X-Telegram-Bot-Api-Secret-Token`) != p.SecretToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var update tb.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case dest <- update:
+		case <-stop:
+			// Telegram sends the update again after the restart
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+}
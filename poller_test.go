@@ -0,0 +1,210 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"dexbot/config"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// fakeTelegram answers the Bot API methods used to set up the pollers
+// and records the parameters they were called with
+type fakeTelegram struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	calls map[string][]map[string]interface{}
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	f := &fakeTelegram{calls: map[string][]map[string]interface{}{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := path.Base(r.URL.Path)
+
+		params := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&params)
+		f.mu.Lock()
+		f.calls[method] = append(f.calls[method], params)
+		f.mu.Unlock()
+
+		switch method {
+		case `getMe`:
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"DexBot","username":"dexbot"}}`)
+		case `setWebhook`, `deleteWebhook`:
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeTelegram) called(method string) []map[string]interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen(`tcp`, `127.0.0.1:0`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestLongPoller(t *testing.T) {
+	api := newFakeTelegram(t)
+
+	c := config.Default()
+	poller := newPoller(&c)
+	if _, ok := poller.(*tb.LongPoller); !ok {
+		t.Fatalf(`newPoller() = %T, want *tb.LongPoller`, poller)
+	}
+
+	bot, err := tb.NewBot(tb.Settings{URL: api.URL, Token: `token`, Poller: poller, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerPoller(bot, poller); err != nil {
+		t.Fatal(err)
+	}
+	if len(api.called(`deleteWebhook`)) != 1 {
+		t.Error(`registerPoller() did not remove the webhook before long polling`)
+	}
+}
+
+func TestWebhookPoller(t *testing.T) {
+	api := newFakeTelegram(t)
+
+	c := config.Default()
+	c.Poller = `webhook`
+	c.WebhookListen = freeAddr(t)
+	c.WebhookURL = `https://bot.example/hook`
+	c.WebhookSecret = `secret`
+
+	poller := newPoller(&c)
+	if _, ok := poller.(webhookPoller); !ok {
+		t.Fatalf(`newPoller() = %T, want webhookPoller`, poller)
+	}
+
+	// tb.Bot.Start races with the requests sent before it, the bot which
+	// talks to the API is kept apart from the one which receives the updates
+	client, err := tb.NewBot(tb.Settings{URL: api.URL, Token: `token`, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := registerPoller(client, poller); err != nil {
+		t.Fatal(err)
+	}
+
+	bot, err := tb.NewBot(tb.Settings{Token: `token`, Poller: poller, Offline: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := make(chan string, 3)
+	bot.Handle(tb.OnText, func(c tb.Context) error {
+		received <- c.Text()
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		bot.Start()
+		close(stopped)
+	}()
+
+	post := func(text, secret string) int {
+		t.Helper()
+
+		update := fmt.Sprintf(`{"update_id":1,"message":{"message_id":1,"date":0,`+
+			`"text":%q,"from":{"id":5},"chat":{"id":5,"type":"private"}}}`, text)
+
+		// The listener is started by bot.Start
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			req, _ := http.NewRequest(http.MethodPost, `http://`+c.WebhookListen, strings.NewReader(update))
+			if len(secret) > 0 {
+				req.Header.Set(`X-Telegram-Bot-Api-Secret-Token`, secret)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			if time.Now().After(deadline) {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	for text, secret := range map[string]string{`unsigned`: ``, `forged`: `wrong`} {
+		if code := post(text, secret); code != http.StatusUnauthorized {
+			t.Errorf(`the %s update got %d, want %d`, text, code, http.StatusUnauthorized)
+		}
+	}
+	if code := post(`signed`, c.WebhookSecret); code != http.StatusOK {
+		t.Errorf(`the signed update got %d, want %d`, code, http.StatusOK)
+	}
+
+	select {
+	case text := <-received:
+		if text != `signed` {
+			t.Errorf(`the update %q was handled, want only the signed one`, text)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(`the signed update was not handled`)
+	}
+
+	bot.Stop()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal(`the bot did not stop`)
+	}
+
+	select {
+	case text := <-received:
+		t.Errorf(`the update %q was handled, want only the signed one`, text)
+	default:
+	}
+
+	// The poller must not set the webhook again
+	calls := api.called(`setWebhook`)
+	if len(calls) != 1 {
+		t.Fatalf(`setWebhook was called %d times, want 1`, len(calls))
+	}
+	if calls[0][`url`] != c.WebhookURL || calls[0][`secret_token`] != c.WebhookSecret {
+		t.Errorf(`setWebhook was called with %v`, calls[0])
+	}
+}
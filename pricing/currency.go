@@ -0,0 +1,118 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package pricing
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type currencyMarker struct {
+	marker   string
+	currency string
+	// word markers must not be glued to other letters, e.g. "EUR" but not "EURO"
+	word bool
+}
+
+var currencyMarkers = []currencyMarker{
+	{`₽`, `RUB`, false},
+	{`руб`, `RUB`, false},
+	{`р.`, `RUB`, false},
+	{`rub`, `RUB`, true},
+	{`rur`, `RUB`, true},
+
+	{`€`, `EUR`, false},
+	{`eur`, `EUR`, true},
+	{`евро`, `EUR`, true},
+
+	{`us$`, `USD`, false},
+	{`$`, `USD`, false},
+	{`usd`, `USD`, true},
+
+	{`£`, `GBP`, false},
+	{`gbp`, `GBP`, true},
+
+	{`₴`, `UAH`, false},
+	{`грн`, `UAH`, false},
+	{`uah`, `UAH`, true},
+
+	{`₸`, `KZT`, false},
+	{`тг`, `KZT`, true},
+	{`kzt`, `KZT`, true},
+
+	{`бел. руб`, `BYN`, false},
+	{`byn`, `BYN`, true},
+
+	{`zł`, `PLN`, true},
+	{`pln`, `PLN`, true},
+
+	{`₺`, `TRY`, false},
+
+	{`¥`, `CNY`, false},
+	{`元`, `CNY`, false},
+	{`cny`, `CNY`, true},
+	{`jpy`, `JPY`, true},
+
+	{`₹`, `INR`, false},
+	{`inr`, `INR`, true},
+
+	{`chf`, `CHF`, true},
+}
+
+func init() {
+	// Longer markers first, so "бел. руб" wins over "руб" and "US$" over "$"
+	sort.SliceStable(currencyMarkers, func(i, j int) bool {
+		return len(currencyMarkers[i].marker) > len(currencyMarkers[j].marker)
+	})
+}
+
+// DetectCurrency returns the ISO 4217 code of the first currency
+// symbol or code found in the text, or an empty string.
+func DetectCurrency(s string) string {
+	s = strings.ToLower(s)
+
+	found, at := ``, len(s)
+	for _, m := range currencyMarkers {
+		i := indexMarker(s, m)
+		if i != -1 && i < at {
+			found, at = m.currency, i
+		}
+	}
+	return found
+}
+
+func indexMarker(s string, m currencyMarker) int {
+	for offset := 0; offset < len(s); {
+		i := strings.Index(s[offset:], m.marker)
+		if i == -1 {
+			return -1
+		}
+		i += offset
+
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[i+len(m.marker):])
+
+		isLetter := func(r rune) bool { return r != utf8.RuneError && unicode.IsLetter(r) }
+		if !isLetter(before) && !(m.word && isLetter(after)) {
+			return i
+		}
+		offset = i + len(m.marker)
+	}
+	return -1
+}
@@ -0,0 +1,95 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package pricing
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+type currencyFormat struct {
+	symbol   string
+	decimals int
+	group    string
+	decimal  string
+	// prefix symbols go before the amount: "$1,299.90"
+	prefix bool
+}
+
+const nbsp = " "
+
+var currencyFormats = map[string]currencyFormat{
+	`RUB`: {`₽`, 2, nbsp, `,`, false},
+	`UAH`: {`₴`, 2, nbsp, `,`, false},
+	`KZT`: {`₸`, 2, nbsp, `,`, false},
+	`BYN`: {`Br`, 2, nbsp, `,`, false},
+	`PLN`: {`zł`, 2, nbsp, `,`, false},
+	`EUR`: {`€`, 2, `.`, `,`, false},
+	`USD`: {`$`, 2, `,`, `.`, true},
+	`GBP`: {`£`, 2, `,`, `.`, true},
+	`CNY`: {`¥`, 2, `,`, `.`, true},
+	`JPY`: {`¥`, 0, `,`, `.`, true},
+	`INR`: {`₹`, 2, `,`, `.`, true},
+	`CHF`: {`CHF` + nbsp, 2, `'`, `.`, true},
+}
+
+// Format renders the amount the way it is usually written in the currency.
+// Decimals are shown only when the amount is not whole. Unknown currencies
+// are written with their code after the amount.
+func Format(amount float64, currency string) string {
+	f, ok := currencyFormats[strings.ToUpper(currency)]
+	if !ok {
+		f = currencyFormat{symbol: currency, decimals: 2, group: nbsp, decimal: `.`}
+	}
+
+	sign := ``
+	if amount < 0 {
+		sign, amount = `-`, -amount
+	}
+
+	decimals := f.decimals
+	if amount == math.Trunc(amount) {
+		decimals = 0
+	}
+
+	s := strconv.FormatFloat(amount, 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(s, `.`)
+
+	var b strings.Builder
+	b.WriteString(sign)
+	if f.prefix {
+		b.WriteString(f.symbol)
+	}
+
+	for i, r := range whole {
+		if i != 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.group)
+		}
+		b.WriteRune(r)
+	}
+	if len(fraction) != 0 {
+		b.WriteString(f.decimal)
+		b.WriteString(fraction)
+	}
+
+	if !f.prefix && len(f.symbol) != 0 {
+		b.WriteString(nbsp)
+		b.WriteString(f.symbol)
+	}
+	return b.String()
+}
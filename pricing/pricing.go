@@ -0,0 +1,143 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// The package pricing parses prices the way shops display them
+package pricing
+
+import (
+	"dexbot/catcherr"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Price is an amount with the ISO 4217 code of its currency.
+// Currency is empty when the text does not mention any.
+type Price struct {
+	Amount   float64
+	Currency string
+}
+
+// Spaces which shops put between digit groups
+var spaces = strings.NewReplacer(
+	" ", ` `, // no-break space
+	" ", ` `, // narrow no-break space
+	" ", ` `, // thin space
+	" ", ` `, // figure space
+	" ", ` `, // punctuation space
+	"\t", ` `,
+	"\n", ` `,
+)
+
+// Apostrophes are used for grouping in Switzerland
+var apostrophes = strings.NewReplacer(`'`, ` `, `’`, ` `)
+
+var numbers = regexp.MustCompile(`[0-9]+(?:[ .,][0-9]+)*%?`)
+
+// Parse finds the first amount in the text, so for ranges like "от 990"
+// or "990 – 1 290 ₽" the lowest price is returned. Numbers followed by
+// a percent sign are discounts and are skipped.
+func Parse(s string) (Price, error) {
+	s = spaces.Replace(s)
+	s = apostrophes.Replace(s)
+
+	for _, token := range numbers.FindAllString(s, -1) {
+		if strings.HasSuffix(token, `%`) {
+			continue
+		}
+
+		amount, err := parseNumber(token)
+		if err != nil {
+			return Price{}, err
+		}
+		return Price{Amount: amount, Currency: DetectCurrency(s)}, nil
+	}
+	return Price{}, catcherr.MissingPrice(s)
+}
+
+// parseNumber converts a token of digits and separators to a number.
+// When the digit grouping breaks, the rest of the token is a separate
+// number (e.g. "990 1290") and is ignored.
+func parseNumber(token string) (float64, error) {
+	// Spaces are never used as a decimal separator
+	groups := strings.Split(token, ` `)
+	n := 1
+	for n < len(groups) && isGroup(groups[n], n == len(groups)-1) {
+		n++
+	}
+	token = strings.Join(groups[:n], ``)
+
+	decimal := decimalSeparator(token)
+
+	var b strings.Builder
+	for _, r := range token {
+		switch {
+		case unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == decimal:
+			b.WriteRune('.')
+		}
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
+
+// isGroup reports whether the part after a space continues the number.
+// The last part may also carry the decimals, e.g. "1 299,90".
+func isGroup(part string, last bool) bool {
+	digits := part
+	if last {
+		if i := strings.IndexAny(part, `.,`); i != -1 {
+			digits = part[:i]
+		}
+	}
+	return len(digits) == 3 && !strings.ContainsAny(digits, `.,`)
+}
+
+// decimalSeparator guesses which of the dot and the comma separates decimals.
+// It returns zero when the number is whole.
+func decimalSeparator(token string) rune {
+	dot := strings.LastIndex(token, `.`)
+	comma := strings.LastIndex(token, `,`)
+
+	switch {
+	case dot != -1 && comma != -1:
+		// "1.299,90" and "1,299.90": the last one is decimal
+		if dot > comma {
+			return '.'
+		}
+		return ','
+	case dot == -1 && comma == -1:
+		return 0
+	}
+
+	sep, last := '.', dot
+	if comma != -1 {
+		sep, last = ',', comma
+	}
+
+	// Repeated separator can only group thousands: "1.299.000"
+	if strings.Count(token, string(sep)) > 1 {
+		return 0
+	}
+
+	// Three digits after a single separator mean thousands ("1,299", "1.299"),
+	// unless the integer part is zero ("0,500").
+	if len(token)-last-1 == 3 && strings.Trim(token[:last], `0`) != `` {
+		return 0
+	}
+	return sep
+}
@@ -0,0 +1,112 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package pricing
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		amount   float64
+		currency string
+	}{
+		{`space groups`, `1 299,90 ₽`, 1299.90, `RUB`},
+		{`no-break space groups`, "1\u00a0299,90\u00a0₽", 1299.90, `RUB`},
+		{`narrow no-break space groups`, "1\u202f299,90\u202f₽", 1299.90, `RUB`},
+		{`thin space groups`, "1\u2009299 ₽", 1299, `RUB`},
+		{`dot groups, comma decimals`, `1.299,90 €`, 1299.90, `EUR`},
+		{`comma groups, dot decimals`, `$1,299.90`, 1299.90, `USD`},
+		{`several groups`, `1 299 990 ₽`, 1299990, `RUB`},
+		{`whole`, `990`, 990, ``},
+		{`comma decimals`, `990,50 руб.`, 990.50, `RUB`},
+		{`dot decimals`, `990.50 USD`, 990.50, `USD`},
+		{`decimals of three digits`, `0,500`, 0.5, ``},
+		{`group of three digits`, `1,299`, 1299, ``},
+		{`dot group of three digits`, `1.299`, 1299, ``},
+		{`from`, `from 990`, 990, ``},
+		{`from in russian`, `от 990 ₽`, 990, `RUB`},
+		{`range`, `990 – 1 290 ₽`, 990, `RUB`},
+		{`range with a hyphen`, `990-1290 ₽`, 990, `RUB`},
+		{`range without grouping`, `990 1290`, 990, ``},
+		{`discount first`, `-20% 1 299 ₽`, 1299, `RUB`},
+		{`discount with decimals`, `12,5% 990 ₽`, 990, `RUB`},
+		{`swiss apostrophes`, `CHF 1'299.90`, 1299.90, `CHF`},
+		{`code before`, `RUB 1 299`, 1299, `RUB`},
+		{`code after`, `1299 UAH`, 1299, `UAH`},
+		{`code in lower case`, `1299 uah`, 1299, `UAH`},
+		{`code glued to a word`, `1299 EURO`, 1299, ``},
+		{`hryvnia`, `1 299 грн`, 1299, `UAH`},
+		{`tenge`, `1 299 тг`, 1299, `KZT`},
+		{`belarusian rouble`, `1 299 бел. руб.`, 1299, `BYN`},
+		{`zloty`, `1 299,99 zł`, 1299.99, `PLN`},
+		{`us dollars`, `US$1,299`, 1299, `USD`},
+		{`pounds`, `£1,299.90`, 1299.90, `GBP`},
+		{`rupees`, `₹1,299`, 1299, `INR`},
+		{`yen`, `1299 JPY`, 1299, `JPY`},
+		{`surrounding text`, "Цена:\n\t1 299 ₽\n", 1299, `RUB`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if err != nil {
+				t.Fatalf(`Parse(%q) returned error: %v`, tt.in, err)
+			}
+			if got.Amount != tt.amount || got.Currency != tt.currency {
+				t.Errorf(`Parse(%q) = %v %q, want %v %q`, tt.in, got.Amount, got.Currency, tt.amount, tt.currency)
+			}
+		})
+	}
+}
+
+func TestParseMissingPrice(t *testing.T) {
+	for _, in := range []string{``, `Нет в наличии`, `-20%`, `₽`} {
+		if got, err := Parse(in); err == nil {
+			t.Errorf(`Parse(%q) = %v, want an error`, in, got)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{1299, `RUB`, `1 299 ₽`},
+		{1299.9, `RUB`, `1 299,90 ₽`},
+		{990, `rub`, `990 ₽`},
+		{1299990, `UAH`, `1 299 990 ₴`},
+		{1299.9, `EUR`, `1.299,90 €`},
+		{1299.9, `USD`, `$1,299.90`},
+		{1299, `GBP`, `£1,299`},
+		{1299.4, `JPY`, `¥1,299`},
+		{1299.9, `CHF`, `CHF 1'299.90`},
+		{-300, `RUB`, `-300 ₽`},
+		{-1299.9, `USD`, `-$1,299.90`},
+		{0, `RUB`, `0 ₽`},
+		{1299.9, `XYZ`, `1 299.90 XYZ`},
+		{1299, ``, `1 299`},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.amount, tt.currency); got != tt.want {
+			t.Errorf(`Format(%v, %q) = %q, want %q`, tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
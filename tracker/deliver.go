@@ -0,0 +1,173 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+	"dexbot/catcherr"
+	"dexbot/database"
+	"dexbot/messages"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// How often the held back notifications are checked, the quiet hours and the digests are set to a minute
+const deliveryInterval = time.Minute
+
+// deliverHeldBack sends the notifications held back during the quiet hours
+// once they end, and the digests when they are due, until the context is cancelled
+func deliverHeldBack(ctx context.Context, bot *tb.Bot, store database.Store) {
+	const errorSender = `tracker.deliverHeldBack()`
+
+	t := time.NewTicker(deliveryInterval)
+	defer t.Stop()
+
+	for {
+		err := deliver(ctx, bot, store, time.Now())
+		if ctx.Err() != nil {
+			return
+		}
+		catcherr.LogError(errorSender, err)
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver sends the held back notifications of the users whose quiet hours
+// are over and the digests of the users whose digest time has come.
+// The digest is sent at the chosen time regardless of the quiet hours.
+func deliver(ctx context.Context, bot *tb.Bot, store database.Store, now time.Time) error {
+	const errorSender = `tracker.deliver()`
+
+	queued, err := store.GetQueuedNotifications(ctx)
+	if err != nil {
+		return err
+	}
+
+	var users []int64
+	byUser := map[int64][]database.Notification{}
+	for _, v := range queued {
+		if _, ok := byUser[v.UserID]; !ok {
+			users = append(users, v.UserID)
+		}
+		byUser[v.UserID] = append(byUser[v.UserID], v)
+	}
+
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		user, err := store.GetUser(ctx, userID)
+		if err != nil {
+			catcherr.LogError(errorSender, err)
+			continue
+		}
+
+		notifications := byUser[userID]
+		digest := len(user.Digest) != 0
+		switch {
+		case digest && !user.DigestDue(notifications[0].CreatedAt, now):
+			continue
+		case !digest && user.IsQuiet(now):
+			continue
+		}
+
+		itemList, err := store.GetItemList(ctx, userID)
+		if err != nil {
+			catcherr.LogError(errorSender, err)
+			continue
+		}
+
+		var ids []int64
+		for _, v := range notifications {
+			ids = append(ids, v.ID)
+		}
+
+		// The notifications are not sent twice if some of them fail
+		err = store.DeleteNotifications(ctx, ids)
+		if err != nil {
+			catcherr.LogError(errorSender, err)
+			continue
+		}
+
+		changes := heldBackChanges(notifications, itemList)
+		if digest {
+			err = sendDigest(ctx, bot, store, user, notifications[0].CreatedAt, changes, itemList, now)
+			catcherr.LogError(errorSender, err)
+			continue
+		}
+
+		l := messages.Get(user.Lang())
+		for _, data := range changes {
+			// The merged change is checked again, e.g. a drop
+			// may be taken back by a rise during the night
+			if shouldNotify(data.OldPrice, data.CurrentPrice, data.TargetPrice) {
+				catcherr.LogError(errorSender, send(ctx, bot, store, l, data, itemList))
+			}
+		}
+	}
+	return nil
+}
+
+// heldBackChanges merges the notifications about the same item into one change
+// from the first old price to the last new one. The items which were deleted
+// and the prices which came back to where they were are left out.
+func heldBackChanges(queued []database.Notification, itemList []database.Item) (changes []priceData) {
+	items := map[int64]database.Item{}
+	for _, v := range itemList {
+		items[v.ID] = v
+	}
+
+	index := map[int64]int{}
+	for _, v := range queued {
+		item, ok := items[v.ItemID]
+		if !ok {
+			continue
+		}
+
+		if i, ok := index[v.ItemID]; ok {
+			changes[i].CurrentPrice, changes[i].Currency = v.NewPrice, v.Currency
+			continue
+		}
+
+		index[v.ItemID] = len(changes)
+		changes = append(changes, priceData{
+			ItemID:       item.ID,
+			UserID:       item.UserID,
+			ItemURL:      item.ItemURL,
+			Title:        item.Title,
+			OldPrice:     v.OldPrice,
+			CurrentPrice: v.NewPrice,
+			TargetPrice:  item.TargetPrice,
+			Currency:     v.Currency,
+		})
+	}
+
+	result := changes[:0]
+	for _, v := range changes {
+		if v.OldPrice != v.CurrentPrice {
+			result = append(result, v)
+		}
+	}
+	return result
+}
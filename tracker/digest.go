@@ -0,0 +1,100 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+	"dexbot/actions"
+	"dexbot/database"
+	"dexbot/messages"
+	"dexbot/metrics"
+	"dexbot/pricing"
+	"sort"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// sendDigest sends the changes since the last digest, or since the oldest
+// change when there was no digest yet, and remembers when it was sent
+func sendDigest(
+
+	ctx context.Context,
+	bot *tb.Bot,
+	store database.UserStore,
+	user database.User,
+	oldest time.Time,
+	changes []priceData,
+	itemList []database.Item,
+	now time.Time,
+
+) error {
+
+	since := user.LastDigestAt
+	if since.IsZero() {
+		since = oldest
+	}
+
+	user.LastDigestAt = now
+	if err := store.SaveUser(ctx, &user); err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	l := messages.Get(user.Lang())
+	formatted := since.In(user.Location()).Format(l.Text(messages.TimeLayout))
+	for _, msg := range l.Digest(formatted, digestChanges(changes, itemList)) {
+		_, err := bot.Send(&tb.User{ID: user.ID}, msg, tb.NoPreview)
+		metrics.Notifications.WithLabelValues(metrics.Result(err)).Inc()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestChanges puts the biggest drops first and the biggest rises last
+func digestChanges(changes []priceData, itemList []database.Item) (result []messages.DigestChange) {
+	nums := map[int64]int{}
+	for i, v := range itemList {
+		nums[v.ID] = i + 1
+	}
+
+	for _, v := range changes {
+		result = append(result, messages.DigestChange{
+			Num:      nums[v.ItemID],
+			URL:      actions.TrimURLScheme(v.ItemURL),
+			OldPrice: pricing.Format(v.OldPrice, v.Currency),
+			NewPrice: pricing.Format(v.CurrentPrice, v.Currency),
+			Percent:  percentChange(v.OldPrice, v.CurrentPrice),
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Percent < result[j].Percent
+	})
+	return result
+}
+
+func percentChange(oldPrice, currentPrice float64) float64 {
+	if oldPrice <= 0 {
+		return 0
+	}
+	return (currentPrice - oldPrice) / oldPrice * 100
+}
@@ -0,0 +1,119 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tracker
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter keeps a token bucket per shop, so that parallel checks
+// do not flood any single host with requests.
+type hostLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	limit    rate.Limit
+	burst    int
+}
+
+func newHostLimiter(requestsPerSecond float64, burst int) *hostLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &hostLimiter{
+		limiters: map[string]*rate.Limiter{},
+		limit:    rate.Limit(requestsPerSecond),
+		burst:    burst,
+	}
+}
+
+// SetRate changes the rate of all the hosts
+func (l *hostLimiter) SetRate(requestsPerSecond float64, burst int) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = rate.Limit(requestsPerSecond)
+	l.burst = burst
+	for _, limiter := range l.limiters {
+		limiter.SetLimit(l.limit)
+		limiter.SetBurst(l.burst)
+	}
+}
+
+// Wait blocks until the host of the link may be requested again
+func (l *hostLimiter) Wait(ctx context.Context, link string) error {
+	host := hostOf(link)
+
+	l.mu.Lock()
+	if l.limit <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	limiter, ok := l.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[host] = limiter
+	}
+	l.mu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// interleaveByHost orders groups so that neighbours belong to different hosts.
+// Otherwise all the workers could end up waiting for the same host
+// while the pages of other hosts are queued behind them.
+func interleaveByHost(groups []itemGroup) []itemGroup {
+	var (
+		hosts  []string
+		byHost = map[string][]itemGroup{}
+	)
+	for _, v := range groups {
+		host := hostOf(v.URL)
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], v)
+	}
+
+	result := make([]itemGroup, 0, len(groups))
+	for len(result) < len(groups) {
+		for _, host := range hosts {
+			if queue := byHost[host]; len(queue) != 0 {
+				result = append(result, queue[0])
+				byHost[host] = queue[1:]
+			}
+		}
+	}
+	return result
+}
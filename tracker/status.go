@@ -0,0 +1,62 @@
+/*
+   Copyright 2022 dexenrage
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package tracker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Cycle describes a completed check of all the items
+type Cycle struct {
+	Finished time.Time
+	Duration time.Duration
+	// Budget is the time a cycle is expected to fit in,
+	// which is the interval between the cycles
+	Budget time.Duration
+}
+
+func (c Cycle) BudgetExceeded() bool { return c.Duration > c.Budget }
+
+// Status is the state of the tracker for the health checks
+type Status struct {
+	// Started is zero until the tracker starts
+	Started   time.Time
+	Budget    time.Duration
+	LastCycle *Cycle
+}
+
+var status atomic.Pointer[Status]
+
+// CurrentStatus returns the state of the tracker
+func CurrentStatus() Status {
+	if s := status.Load(); s != nil {
+		return *s
+	}
+	return Status{}
+}
+
+func setStarted(budget time.Duration) {
+	status.Store(&Status{Started: time.Now(), Budget: budget})
+}
+
+func setCycle(cycle Cycle) {
+	s := CurrentStatus()
+	s.Budget = cycle.Budget
+	s.LastCycle = &cycle
+	status.Store(&s)
+}
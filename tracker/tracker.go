@@ -23,7 +23,9 @@ import (
 	"dexbot/config"
 	"dexbot/database"
 	"dexbot/messages"
-	"fmt"
+	"dexbot/metrics"
+	"dexbot/pricing"
+	"sync"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -31,123 +33,373 @@ import (
 )
 
 type priceData struct {
+	ItemID       int64
 	UserID       int64
 	ItemURL      string
+	Title        string
 	OldPrice     float64
 	CurrentPrice float64
+	TargetPrice  float64
+	Currency     string
+	// Outdated is true when the stored currency or title differs from the page
+	Outdated bool
 }
 
-func Start(bot *tb.Bot) {
+// Start checks the prices every duration until the context is cancelled.
+// The cycle in progress is abandoned: the fetches and the queries stop
+// and the items which were not checked yet are left for the next start.
+func Start(ctx context.Context, bot *tb.Bot, store database.Store) {
 	const errorSender = `tracker.Start()`
-	defer catcherr.Recover(errorSender)
 
-	var (
-		duration = config.String(`duration`)
-		ctx      = context.Background()
-	)
+	c := config.Get()
+	limiter := newHostLimiter(c.RateLimit, c.RateBurst)
+	config.OnChange(func(c *config.Config) {
+		limiter.SetRate(c.RateLimit, c.RateBurst)
+	})
+	setStarted(c.Duration)
 
-	for {
-		g, ctx := errgroup.WithContext(ctx)
-		g.Go(func() error { return timer(duration) })
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		deliverHeldBack(ctx, bot, store)
+	}()
+
+	for ctx.Err() == nil {
+		// A new duration is used from the next cycle
+		duration := config.Get().Duration
+
+		g, cycleCtx := errgroup.WithContext(ctx)
+		g.Go(func() error { return timer(ctx, duration) })
 
 		g.Go(func() error {
-			data := tracker(ctx)
+			start := time.Now()
+			data, err := tracker(cycleCtx, store, limiter)
+			if err != nil {
+				return err
+			}
+
 			for _, v := range data {
+				if cycleCtx.Err() != nil {
+					return cycleCtx.Err()
+				}
 				if v.OldPrice == v.CurrentPrice {
+					// Items saved before the currency and the title were kept get them here
+					if v.Outdated {
+						err := store.UpdatePrice(cycleCtx, v.ItemID, v.CurrentPrice, v.Currency, v.Title)
+						catcherr.LogError(errorSender, err)
+					}
 					continue
 				}
 
-				itemList, err := database.GetItemList(ctx, v.UserID)
+				itemList, err := store.GetItemList(cycleCtx, v.UserID)
 				if err != nil {
 					catcherr.LogError(errorSender, err)
 					continue
 				}
 
-				err = database.UpdatePrice(ctx, v.UserID, v.ItemURL, v.CurrentPrice)
+				err = store.UpdatePrice(cycleCtx, v.ItemID, v.CurrentPrice, v.Currency, v.Title)
 				if err != nil {
 					catcherr.LogError(errorSender, err)
 					continue
 				}
+				// Items added out of stock get their first price, it is not a change
+				if v.OldPrice <= 0 {
+					continue
+				}
 
-				msg := prepareMessage(v.ItemURL, v.OldPrice, v.CurrentPrice, itemList)
-				_, err = bot.Send(&tb.User{ID: v.UserID}, msg, tb.NoPreview)
+				err = notify(cycleCtx, bot, store, v, itemList)
 				catcherr.LogError(errorSender, err)
 			}
+
+			setCycle(Cycle{
+				Finished: time.Now(),
+				Duration: time.Since(start),
+				Budget:   duration,
+			})
 			return nil
 		})
-		catcherr.HandleError(g.Wait())
+
+		err := g.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		catcherr.LogError(errorSender, err)
+	}
+}
+
+// notify sends the notification about the price change, or holds it back during
+// the quiet hours of the user. The digest gets all the changes, not only
+// the ones which pass shouldNotify.
+func notify(
+
+	ctx context.Context,
+	bot *tb.Bot,
+	store database.Store,
+	data priceData,
+	itemList []database.Item,
+
+) error {
+
+	user, err := store.GetUser(ctx, data.UserID)
+	if err != nil {
+		return err
+	}
+
+	digest := len(user.Digest) != 0
+	if !digest && !shouldNotify(data.OldPrice, data.CurrentPrice, data.TargetPrice) {
+		return nil
+	}
+
+	if digest || user.IsQuiet(time.Now()) {
+		return store.QueueNotification(ctx, &database.Notification{
+			UserID:   data.UserID,
+			ItemID:   data.ItemID,
+			OldPrice: data.OldPrice,
+			NewPrice: data.CurrentPrice,
+			Currency: data.Currency,
+		})
 	}
+	return send(ctx, bot, store, messages.Get(user.Lang()), data, itemList)
 }
 
-func prepareMessage(
+func send(
 
-	itemURL string,
-	oldPrice float64,
-	currentPrice float64,
+	ctx context.Context,
+	bot *tb.Bot,
+	store database.HistoryStore,
+	l *messages.Locale,
+	data priceData,
 	itemList []database.Item,
 
-) (message string) {
+) error {
+
+	// The notification is sent without the lowest price if the history fails
+	history, err := store.GetPriceHistory(ctx, data.ItemID)
+	catcherr.LogError(`tracker.send()`, err)
+
+	msg := prepareMessage(l, data, itemList, lowestPrice(history))
+	_, err = bot.Send(&tb.User{ID: data.UserID}, msg, tb.NoPreview)
+	metrics.Notifications.WithLabelValues(metrics.Result(err)).Inc()
+	return err
+}
 
-	var itemID int
+func prepareMessage(l *messages.Locale, data priceData, itemList []database.Item, lowest float64) string {
+	n := messages.Notification{
+		Title:   data.Title,
+		URL:     actions.TrimURLScheme(data.ItemURL),
+		Old:     pricing.Format(data.OldPrice, data.Currency),
+		New:     pricing.Format(data.CurrentPrice, data.Currency),
+		Delta:   formatDelta(data.CurrentPrice-data.OldPrice, data.Currency),
+		Up:      data.OldPrice < data.CurrentPrice,
+		Percent: percentChange(data.OldPrice, data.CurrentPrice),
+	}
 	for i, v := range itemList {
-		if v.ItemURL == itemURL {
-			itemID = i + 1
+		if v.ID == data.ItemID {
+			n.Num = i + 1
 			break
 		}
 	}
+	if lowest > 0 {
+		n.Lowest = pricing.Format(lowest, data.Currency)
+	}
+	if data.TargetPrice > 0 {
+		n.Target = pricing.Format(data.TargetPrice, data.Currency)
+	}
+	return l.Notification(n)
+}
 
-	var priceStatus string
-	switch {
-	case oldPrice < currentPrice:
-		priceStatus = messages.PriceUp
-	case oldPrice > currentPrice:
-		priceStatus = messages.PriceDown
-	}
-
-	message = fmt.Sprintf(
-		messages.ChangedPriceTemplate,
-		priceStatus,
-		itemID,
-		actions.TrimURLScheme(itemURL),
-		oldPrice,
-		currentPrice,
-	)
-	return message
+// formatDelta formats the difference of the prices with the sign, like +300 ₽
+func formatDelta(delta float64, currency string) string {
+	if delta > 0 {
+		return `+` + pricing.Format(delta, currency)
+	}
+	return pricing.Format(delta, currency)
 }
 
-func tracker(ctx context.Context) (data []priceData) {
-	items, err := database.GetAllItems(ctx)
-	catcherr.HandleError(err)
+// lowestPrice returns the lowest price ever seen, zero when there is no history
+func lowestPrice(history []database.PriceHistory) (lowest float64) {
+	for i, v := range history {
+		if i == 0 || v.Price < lowest {
+			lowest = v.Price
+		}
+	}
+	return lowest
+}
 
-	for _, v := range items {
-		g, ctx := errgroup.WithContext(ctx)
-		g.Go(func() (err error) {
-			defer func() { err = catcherr.RecoverAndReturnError() }()
-
-			price, err := actions.GetPrice(ctx, v.ItemURL)
-			catcherr.HandleError(err)
-
-			data = append(data, priceData{
-				UserID:       v.UserID,
-				ItemURL:      v.ItemURL,
-				OldPrice:     v.Price,
-				CurrentPrice: price,
-			})
-			return err
+// shouldNotify filters out price changes the user does not care about.
+// Items with a target price notify only when the price crosses below it,
+// other items notify on drops of at least notify_drop_percent. The rises
+// are never notified about.
+func shouldNotify(oldPrice, currentPrice, targetPrice float64) bool {
+	if currentPrice >= oldPrice {
+		return false
+	}
+
+	if targetPrice > 0 {
+		return oldPrice > targetPrice && currentPrice <= targetPrice
+	}
+
+	percent := config.Get().NotifyDropPercent
+	if percent <= 0 {
+		return true
+	}
+	return (oldPrice-currentPrice)/oldPrice*100 >= percent
+}
+
+// tracker checks all the items with a bounded number of workers.
+// Every page is fetched once, however many users track it.
+func tracker(ctx context.Context, store database.Store, limiter *hostLimiter) (data []priceData, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.TrackerCycleDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	items, err := store.GetAllItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The paused items are still tracked, they are only not checked now
+	metrics.TrackedItems.Set(float64(len(items)))
+	items = activeItems(items, time.Now())
+
+	workers := config.Get().TrackerWorkers
+
+	var mu sync.Mutex
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+
+	for _, group := range interleaveByHost(groupByURL(items)) {
+		group := group
+		g.Go(func() error {
+			if err := limiter.Wait(ctx, group.URL); err != nil {
+				return err
+			}
+
+			product, fetchErr := actions.GetProduct(ctx, group.URL)
+			if ctx.Err() != nil {
+				// Cancelled fetch says nothing about the page
+				return ctx.Err()
+			}
+			catcherr.LogError(`tracker.tracker()`, fetchErr)
+
+			for _, v := range group.Items {
+				d, ok := observe(ctx, store, v, product, fetchErr)
+				if ok {
+					mu.Lock()
+					data = append(data, d)
+					mu.Unlock()
+				}
+			}
+			return nil
 		})
-		catcherr.LogError(`tracker.tracker()`, g.Wait())
-		time.Sleep(1 * time.Second) // To avoid HTTP request flood
 	}
-	return data
+	// The appends of the workers are complete only after Wait
+	err = g.Wait()
+	return data, err
 }
 
-func timer(duration string) (err error) {
-	defer func() { err = catcherr.RecoverAndReturnError() }()
+// activeItems leaves out the paused items
+func activeItems(items []database.Item, now time.Time) (active []database.Item) {
+	for _, v := range items {
+		if !v.IsPaused(now) {
+			active = append(active, v)
+		}
+	}
+	return active
+}
 
-	d, err := time.ParseDuration(duration)
-	catcherr.HandleError(err)
+// itemGroup is the items of different users which point to the same page
+type itemGroup struct {
+	URL   string
+	Items []database.Item
+}
 
-	dur := time.Now().Add(d)
-	time.Sleep(time.Until(dur))
-	return err
+func groupByURL(items []database.Item) (groups []itemGroup) {
+	index := map[string]int{}
+	for _, v := range items {
+		canonical := actions.CanonicalURL(v.ItemURL)
+
+		i, ok := index[canonical]
+		if !ok {
+			i = len(groups)
+			index[canonical] = i
+			groups = append(groups, itemGroup{URL: v.ItemURL})
+		}
+		groups[i].Items = append(groups[i].Items, v)
+	}
+	return groups
+}
+
+// observe records the result of the page fetch in the price history of the item.
+// It reports false when there is no price to compare with the old one.
+func observe(
+
+	ctx context.Context,
+	store database.HistoryStore,
+	item database.Item,
+	product actions.Product,
+	fetchErr error,
+
+) (data priceData, ok bool) {
+
+	const errorSender = `tracker.observe()`
+
+	var status string
+	switch {
+	case fetchErr != nil:
+		status = database.StatusFailed
+	case !product.Available:
+		// The price of an item which is out of stock is not trustworthy
+		status = database.StatusUnavailable
+	default:
+		status = database.StatusOK
+	}
+
+	var price float64
+	if status == database.StatusOK {
+		price = product.Price
+	}
+
+	err := store.AddPriceHistory(ctx, item.ID, price, status)
+	catcherr.LogError(errorSender, err)
+
+	if status != database.StatusOK {
+		return data, false
+	}
+
+	// The stored title is used when the page has none
+	title := product.Title
+	if len(title) == 0 {
+		title = item.Title
+	}
+
+	data = priceData{
+		ItemID:       item.ID,
+		UserID:       item.UserID,
+		ItemURL:      item.ItemURL,
+		Title:        title,
+		OldPrice:     item.Price,
+		CurrentPrice: product.Price,
+		TargetPrice:  item.TargetPrice,
+		Currency:     product.Currency,
+		Outdated:     item.Currency != product.Currency || item.Title != title,
+	}
+	return data, true
+}
+
+// timer waits for the duration or until the context is cancelled
+func timer(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }